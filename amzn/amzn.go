@@ -4,6 +4,7 @@ package amzn
 import (
 	"sync"
 
+	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/session"
 )
 
@@ -13,6 +14,9 @@ var (
 	// if necessary. The default implementation returns a session
 	// with defaults obtained from the environment.
 	AWSSession func() *session.Session
+
+	// defaultClient is used by the package-level functions.
+	defaultClient = &Client{}
 )
 
 func init() {
@@ -26,3 +30,35 @@ func init() {
 		return sess
 	}
 }
+
+// Client performs AWS operations using an AWS session. Programs that
+// need to access more than one AWS account can create a client for
+// each account. The zero value is ready to use, and uses the session
+// returned by AWSSession.
+type Client struct {
+	// Session is the AWS session used for all operations. If nil,
+	// the session returned by AWSSession is used.
+	Session *session.Session
+
+	// DisableRetries disables the retries of the AWS SDK for S3, SSM and
+	// Secrets Manager requests. It is used by callers that retry failed
+	// requests themselves.
+	DisableRetries bool
+}
+
+func (c *Client) session() *session.Session {
+	if c == nil || c.Session == nil {
+		return AWSSession()
+	}
+	return c.Session
+}
+
+// config returns the configuration for S3, SSM and Secrets Manager
+// service clients.
+func (c *Client) config() *aws.Config {
+	config := aws.NewConfig()
+	if c != nil && c.DisableRetries {
+		config = config.WithMaxRetries(0)
+	}
+	return config
+}
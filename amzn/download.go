@@ -1,6 +1,7 @@
 package amzn
 
 import (
+	"context"
 	"io"
 	"net/http"
 	"time"
@@ -13,39 +14,126 @@ import (
 // Get the contents of an S3 bucket. The caller is responsible for
 // closing the body.
 func Get(bucket, key string) (etag string, modified time.Time, body io.ReadCloser, err error) {
-	s3svc := s3.New(AWSSession())
-	output, err := s3svc.GetObject(&s3.GetObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-	})
+	return defaultClient.Get(context.Background(), bucket, key)
+}
+
+// GetContext gets the contents of an S3 bucket. The caller is responsible
+// for closing the body. Cancelling the context aborts the request.
+func GetContext(ctx context.Context, bucket, key string) (etag string, modified time.Time, body io.ReadCloser, err error) {
+	return defaultClient.Get(ctx, bucket, key)
+}
+
+// Head the contents of an S3 bucket.
+func Head(bucket, key string) (etag string, modified time.Time, err error) {
+	return defaultClient.Head(context.Background(), bucket, key)
+}
+
+// HeadContext heads the contents of an S3 bucket. Cancelling the context
+// aborts the request.
+func HeadContext(ctx context.Context, bucket, key string) (etag string, modified time.Time, err error) {
+	return defaultClient.Head(ctx, bucket, key)
+}
+
+// HasChanged determines whether the S3 object has changed.
+func HasChanged(bucket, key string, etag string) (changed bool, err error) {
+	return defaultClient.HasChanged(context.Background(), bucket, key, etag)
+}
+
+// HasChangedContext determines whether the S3 object has changed.
+// Cancelling the context aborts the request.
+func HasChangedContext(ctx context.Context, bucket, key string, etag string) (changed bool, err error) {
+	return defaultClient.HasChanged(ctx, bucket, key, etag)
+}
+
+// Get the contents of an S3 bucket. The caller is responsible for
+// closing the body. Cancelling the context aborts the request.
+func (c *Client) Get(ctx context.Context, bucket, key string) (etag string, modified time.Time, body io.ReadCloser, err error) {
+	return c.GetObject(ctx, &S3Object{Bucket: bucket, Key: key})
+}
+
+// GetObject gets the contents of an S3 object. The caller is responsible
+// for closing the body. Cancelling the context aborts the request.
+func (c *Client) GetObject(ctx context.Context, obj *S3Object) (etag string, modified time.Time, body io.ReadCloser, err error) {
+	etag, modified, body, _, err = c.getObject(ctx, obj, "")
+	return etag, modified, body, err
+}
+
+// GetIfChanged gets the contents of an S3 bucket if its ETag does not match
+// etag. If the object has not changed, changed is false and body is nil.
+// Otherwise the caller is responsible for closing the body. Cancelling the
+// context aborts the request.
+func (c *Client) GetIfChanged(ctx context.Context, bucket, key string, etag string) (newEtag string, modified time.Time, body io.ReadCloser, changed bool, err error) {
+	return c.getObject(ctx, &S3Object{Bucket: bucket, Key: key}, etag)
+}
+
+// GetObjectIfChanged is like GetIfChanged, for an S3 object.
+func (c *Client) GetObjectIfChanged(ctx context.Context, obj *S3Object, etag string) (newEtag string, modified time.Time, body io.ReadCloser, changed bool, err error) {
+	return c.getObject(ctx, obj, etag)
+}
+
+// getObject gets the contents of an S3 object. If etag is not empty,
+// the request is conditional on the object not matching the ETag.
+func (c *Client) getObject(ctx context.Context, obj *S3Object, etag string) (newEtag string, modified time.Time, body io.ReadCloser, changed bool, err error) {
+	s3svc, err := c.s3(obj)
 	if err != nil {
+		return newEtag, modified, body, false, err
+	}
+	input := &s3.GetObjectInput{
+		Bucket: aws.String(obj.Bucket),
+		Key:    aws.String(obj.Key),
+	}
+	if etag != "" {
+		input.IfNoneMatch = aws.String(etag)
+	}
+	if obj.VersionID != "" {
+		input.VersionId = aws.String(obj.VersionID)
+	}
+	output, err := s3svc.GetObjectWithContext(ctx, input)
+	if err != nil {
+		if etag != "" && isNotModified(err) {
+			return etag, modified, nil, false, nil
+		}
 		err = errors.Wrap(err, "cannot download from S3").With(
-			"bucket", bucket,
-			"key", key,
+			"bucket", obj.Bucket,
+			"key", obj.Key,
 		)
-		return etag, modified, body, err
+		return newEtag, modified, body, false, err
 	}
 	if output.ETag != nil {
-		etag = *output.ETag
+		newEtag = *output.ETag
 	}
 	if output.LastModified != nil {
 		modified = *output.LastModified
 	}
 	body = output.Body
-	return etag, modified, body, nil
+	return newEtag, modified, body, true, nil
 }
 
-// Head the contents of an S3 bucket.
-func Head(bucket, key string) (etag string, modified time.Time, err error) {
-	s3svc := s3.New(AWSSession())
-	output, err := s3svc.HeadObject(&s3.HeadObjectInput{
-		Bucket: aws.String(bucket),
-		Key:    aws.String(key),
-	})
+// Head the contents of an S3 bucket. Cancelling the context
+// aborts the request.
+func (c *Client) Head(ctx context.Context, bucket, key string) (etag string, modified time.Time, err error) {
+	return c.HeadObject(ctx, &S3Object{Bucket: bucket, Key: key})
+}
+
+// HeadObject heads an S3 object. Cancelling the context aborts
+// the request.
+func (c *Client) HeadObject(ctx context.Context, obj *S3Object) (etag string, modified time.Time, err error) {
+	s3svc, err := c.s3(obj)
+	if err != nil {
+		return etag, modified, err
+	}
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(obj.Bucket),
+		Key:    aws.String(obj.Key),
+	}
+	if obj.VersionID != "" {
+		input.VersionId = aws.String(obj.VersionID)
+	}
+	output, err := s3svc.HeadObjectWithContext(ctx, input)
 	if err != nil {
 		err = errors.Wrap(err, "cannot download from S3").With(
-			"bucket", bucket,
-			"key", key,
+			"bucket", obj.Bucket,
+			"key", obj.Key,
 		)
 		return etag, modified, err
 	}
@@ -59,25 +147,23 @@ func Head(bucket, key string) (etag string, modified time.Time, err error) {
 }
 
 // HasChanged determines whether the S3 object has changed.
-func HasChanged(bucket, key string, etag string) (changed bool, err error) {
+// Cancelling the context aborts the request.
+func (c *Client) HasChanged(ctx context.Context, bucket, key string, etag string) (changed bool, err error) {
 	// We don't bother with last modified because we know S3 always
 	// returns an ETag and passing both IfNoneMatch and IfModifiedSince
 	// only complicates things as per RFC 7232.
-	s3svc := s3.New(AWSSession())
-	_, err = s3svc.HeadObject(&s3.HeadObjectInput{
+	s3svc, err := c.s3(&S3Object{Bucket: bucket, Key: key})
+	if err != nil {
+		return false, err
+	}
+	_, err = s3svc.HeadObjectWithContext(ctx, &s3.HeadObjectInput{
 		Bucket:      aws.String(bucket),
 		Key:         aws.String(key),
 		IfNoneMatch: aws.String(etag),
 	})
-	type statusCoder interface {
-		StatusCode() int
-	}
 	if err != nil {
-		if statusCode, ok := err.(statusCoder); ok {
-			if statusCode.StatusCode() == http.StatusNotModified {
-				// not modified
-				return false, nil
-			}
+		if isNotModified(err) {
+			return false, nil
 		}
 		return false, errors.Wrap(err, "cannot HEAD S3 object").With(
 			"bucket", bucket,
@@ -87,3 +173,15 @@ func HasChanged(bucket, key string, etag string) (changed bool, err error) {
 	// object has changed
 	return true, nil
 }
+
+// isNotModified reports whether err is an S3 "304 Not Modified" response
+// to a conditional request.
+func isNotModified(err error) bool {
+	type statusCoder interface {
+		StatusCode() int
+	}
+	if statusCode, ok := err.(statusCoder); ok {
+		return statusCode.StatusCode() == http.StatusNotModified
+	}
+	return false
+}
@@ -1,6 +1,7 @@
 package amzn
 
 import (
+	"context"
 	"encoding/base64"
 	"strings"
 
@@ -24,6 +25,20 @@ var (
 // NewKey creates a new data encryption key based on the contents
 // of the configuration file.
 func NewKey(node ast.Node) (encryption.Key, error) {
+	return defaultClient.NewKey(context.Background(), node)
+}
+
+// NewKeyContext creates a new data encryption key based on the contents
+// of the configuration file. Cancelling the context aborts the call to KMS.
+func NewKeyContext(ctx context.Context, node ast.Node) (encryption.Key, error) {
+	return defaultClient.NewKey(ctx, node)
+}
+
+// NewKey creates a new data encryption key based on the contents
+// of the configuration file. If the configuration file does not
+// contain a KMS data key, NewKey returns a nil key and a nil error.
+// Cancelling the context aborts the call to KMS.
+func (c *Client) NewKey(ctx context.Context, node ast.Node) (encryption.Key, error) {
 	var data struct {
 		Encryption struct {
 			KMS *string
@@ -46,8 +61,8 @@ func NewKey(node ast.Node) (encryption.Key, error) {
 		return nil, errors.New("kms encryption: invalid dataKey: not base64")
 	}
 
-	kmssvc := kms.New(AWSSession())
-	output, err := kmssvc.Decrypt(&kms.DecryptInput{
+	kmssvc := kms.New(c.session())
+	output, err := kmssvc.DecryptWithContext(ctx, &kms.DecryptInput{
 		CiphertextBlob:    binaryBlob,
 		EncryptionContext: encryptionContext,
 	})
@@ -62,8 +77,14 @@ func NewKey(node ast.Node) (encryption.Key, error) {
 // GenerateDataKey generates a new data encryption key that can
 // be used in the configuration file.
 func GenerateDataKey(keyID string) (dataKey string, keyARN string, err error) {
-	kmssvc := kms.New(AWSSession())
-	output, err := kmssvc.GenerateDataKey(&kms.GenerateDataKeyInput{
+	return defaultClient.GenerateDataKey(context.Background(), keyID)
+}
+
+// GenerateDataKey generates a new data encryption key that can
+// be used in the configuration file.
+func (c *Client) GenerateDataKey(ctx context.Context, keyID string) (dataKey string, keyARN string, err error) {
+	kmssvc := kms.New(c.session())
+	output, err := kmssvc.GenerateDataKeyWithContext(ctx, &kms.GenerateDataKeyInput{
 		KeyId:             aws.String(keyID),
 		KeySpec:           aws.String("AES_256"),
 		EncryptionContext: encryptionContext,
@@ -0,0 +1,113 @@
+package amzn
+
+import (
+	"sync"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jjeffery/errors"
+)
+
+// S3Object identifies an object in S3, or in an S3-compatible store
+// such as MinIO, and how to access it.
+type S3Object struct {
+	Bucket string
+	Key    string
+
+	// VersionID selects a version of the object. If empty, the
+	// latest version is used.
+	VersionID string
+
+	// Region is the region of the bucket. If empty, the region
+	// of the session is used.
+	Region string
+
+	// Endpoint is the URL of an S3-compatible server, eg
+	// "http://localhost:9000". If empty, the AWS endpoint for
+	// the region is used.
+	Endpoint string
+
+	// Profile is the name of a profile in the AWS shared config
+	// and credentials files. If empty, the client's session is used.
+	Profile string
+
+	// PathStyle selects path-style addressing, where the bucket is
+	// part of the path instead of the host name. Most S3-compatible
+	// servers require path-style addressing.
+	PathStyle bool
+}
+
+// s3ClientKey identifies a cached S3 service client.
+type s3ClientKey struct {
+	session   *session.Session
+	profile   string
+	region    string
+	endpoint  string
+	pathStyle bool
+	noRetries bool
+}
+
+var s3Clients = struct {
+	sync.Mutex
+	profiles map[string]*session.Session
+	clients  map[s3ClientKey]*s3.S3
+}{
+	profiles: make(map[string]*session.Session),
+	clients:  make(map[s3ClientKey]*s3.S3),
+}
+
+// s3 returns the S3 service client for accessing the object. Service
+// clients, and the sessions for profiles, are cached so that credentials
+// are only obtained once for each region, endpoint and profile.
+func (c *Client) s3(obj *S3Object) (*s3.S3, error) {
+	key := s3ClientKey{
+		session:   c.session(),
+		profile:   obj.Profile,
+		region:    obj.Region,
+		endpoint:  obj.Endpoint,
+		pathStyle: obj.PathStyle,
+		noRetries: c != nil && c.DisableRetries,
+	}
+	if key.profile != "" {
+		// the profile replaces the client's session
+		key.session = nil
+	}
+
+	s3Clients.Lock()
+	defer s3Clients.Unlock()
+	if client, ok := s3Clients.clients[key]; ok {
+		return client, nil
+	}
+
+	sess := key.session
+	if key.profile != "" {
+		sess = s3Clients.profiles[key.profile]
+		if sess == nil {
+			var err error
+			sess, err = session.NewSessionWithOptions(session.Options{
+				Profile:           key.profile,
+				SharedConfigState: session.SharedConfigEnable,
+			})
+			if err != nil {
+				return nil, errors.Wrap(err, "cannot create AWS session").With(
+					"profile", key.profile,
+				)
+			}
+			s3Clients.profiles[key.profile] = sess
+		}
+	}
+
+	config := c.config()
+	if key.region != "" {
+		config = config.WithRegion(key.region)
+	}
+	if key.endpoint != "" {
+		config = config.WithEndpoint(key.endpoint)
+	}
+	if key.pathStyle {
+		config = config.WithS3ForcePathStyle(true)
+	}
+	client := s3.New(sess, config)
+	s3Clients.clients[key] = client
+	return client, nil
+}
@@ -0,0 +1,85 @@
+package amzn
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/secretsmanager"
+	"github.com/jjeffery/errors"
+)
+
+// currentStage is the staging label of the current version of a secret.
+const currentStage = "AWSCURRENT"
+
+// GetSecret gets the value of a Secrets Manager secret, along with the ID
+// of the version and the time the version was created. If versionID is
+// empty, the version with the versionStage label is returned. If both are
+// empty, the current version is returned. Cancelling the context aborts
+// the request.
+func (c *Client) GetSecret(ctx context.Context, secretID, versionID, versionStage string) (value []byte, newVersionID string, created time.Time, err error) {
+	input := &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(secretID),
+	}
+	if versionID != "" {
+		input.VersionId = aws.String(versionID)
+	}
+	if versionStage != "" {
+		input.VersionStage = aws.String(versionStage)
+	}
+	smsvc := secretsmanager.New(c.session(), c.config())
+	output, err := smsvc.GetSecretValueWithContext(ctx, input)
+	if err != nil {
+		err = errors.Wrap(err, "cannot get secret").With(
+			"secretId", secretID,
+		)
+		return value, newVersionID, created, err
+	}
+	if output.SecretString != nil {
+		value = []byte(*output.SecretString)
+	} else {
+		value = output.SecretBinary
+	}
+	newVersionID = aws.StringValue(output.VersionId)
+	created = aws.TimeValue(output.CreatedDate)
+	return value, newVersionID, created, nil
+}
+
+// DescribeSecret returns the ID of a version of a Secrets Manager secret,
+// and the time the secret was last changed, without getting its value.
+// See GetSecret for the meaning of versionID and versionStage. Cancelling
+// the context aborts the request.
+func (c *Client) DescribeSecret(ctx context.Context, secretID, versionID, versionStage string) (newVersionID string, modified time.Time, err error) {
+	smsvc := secretsmanager.New(c.session(), c.config())
+	output, err := smsvc.DescribeSecretWithContext(ctx, &secretsmanager.DescribeSecretInput{
+		SecretId: aws.String(secretID),
+	})
+	if err != nil {
+		err = errors.Wrap(err, "cannot describe secret").With(
+			"secretId", secretID,
+		)
+		return newVersionID, modified, err
+	}
+	modified = aws.TimeValue(output.LastChangedDate)
+	if versionID == "" && versionStage == "" {
+		versionStage = currentStage
+	}
+	for id, stages := range output.VersionIdsToStages {
+		if versionID != "" {
+			if id == versionID {
+				return id, modified, nil
+			}
+			continue
+		}
+		for _, stage := range stages {
+			if aws.StringValue(stage) == versionStage {
+				return id, modified, nil
+			}
+		}
+	}
+	return newVersionID, modified, errors.New("cannot find secret version").With(
+		"secretId", secretID,
+		"versionId", versionID,
+		"versionStage", versionStage,
+	)
+}
@@ -0,0 +1,34 @@
+package amzn
+
+import (
+	"context"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ssm"
+	"github.com/jjeffery/errors"
+)
+
+// GetParameter gets the value of an SSM parameter, along with its version
+// and the time it was last modified. If decrypt is true, the value of a
+// SecureString parameter is decrypted. The name can include a version
+// selector, eg "/app/config:3". Cancelling the context aborts the request.
+func (c *Client) GetParameter(ctx context.Context, name string, decrypt bool) (value string, version int64, modified time.Time, err error) {
+	ssmsvc := ssm.New(c.session(), c.config())
+	output, err := ssmsvc.GetParameterWithContext(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(name),
+		WithDecryption: aws.Bool(decrypt),
+	})
+	if err != nil {
+		err = errors.Wrap(err, "cannot get SSM parameter").With(
+			"name", name,
+		)
+		return value, version, modified, err
+	}
+	if p := output.Parameter; p != nil {
+		value = aws.StringValue(p.Value)
+		version = aws.Int64Value(p.Version)
+		modified = aws.TimeValue(p.LastModifiedDate)
+	}
+	return value, version, modified, nil
+}
@@ -0,0 +1,76 @@
+package amzn
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/jjeffery/errors"
+)
+
+// ErrConflict is the cause of the error returned by a conditional write
+// when the object has been modified since the version with the expected
+// ETag. Use errors.Cause to check for it.
+var ErrConflict = errors.New("file has been modified since it was read")
+
+// Put uploads the contents of an S3 object. If ifMatch is not empty, the
+// object is only written if its current ETag matches, and the cause of
+// the error is ErrConflict if it does not. Put returns the ETag of the
+// new version of the object.
+func Put(bucket, key string, body []byte, ifMatch string) (etag string, err error) {
+	return defaultClient.Put(context.Background(), bucket, key, body, ifMatch)
+}
+
+// PutContext is like Put, but cancelling the context aborts the request.
+func PutContext(ctx context.Context, bucket, key string, body []byte, ifMatch string) (etag string, err error) {
+	return defaultClient.Put(ctx, bucket, key, body, ifMatch)
+}
+
+// Put uploads the contents of an S3 object. See the package-level Put
+// function for details. Cancelling the context aborts the request.
+func (c *Client) Put(ctx context.Context, bucket, key string, body []byte, ifMatch string) (etag string, err error) {
+	return c.PutObject(ctx, &S3Object{Bucket: bucket, Key: key}, body, ifMatch)
+}
+
+// PutObject is like Put, for an S3 object. The version ID of the
+// object is ignored.
+func (c *Client) PutObject(ctx context.Context, obj *S3Object, body []byte, ifMatch string) (etag string, err error) {
+	s3svc, err := c.s3(obj)
+	if err != nil {
+		return etag, err
+	}
+	req, output := s3svc.PutObjectRequest(&s3.PutObjectInput{
+		Bucket: aws.String(obj.Bucket),
+		Key:    aws.String(obj.Key),
+		Body:   bytes.NewReader(body),
+	})
+	req.SetContext(ctx)
+	if ifMatch != "" {
+		// the SDK does not model conditional writes, but the
+		// header is signed because it is set before sending
+		req.HTTPRequest.Header.Set("If-Match", ifMatch)
+	}
+	if err := req.Send(); err != nil {
+		if isConflict(err) {
+			err = ErrConflict
+		}
+		return etag, errors.Wrap(err, "cannot upload to S3").With(
+			"bucket", obj.Bucket,
+			"key", obj.Key,
+		)
+	}
+	return aws.StringValue(output.ETag), nil
+}
+
+// isConflict reports whether err is the response to a conditional write
+// of an object that has been modified.
+func isConflict(err error) bool {
+	if reqErr, ok := err.(awserr.RequestFailure); ok {
+		return reqErr.StatusCode() == http.StatusPreconditionFailed ||
+			reqErr.StatusCode() == http.StatusConflict
+	}
+	return false
+}
@@ -30,8 +30,17 @@ type Decrypter interface {
 // is nil this function will return success only if there is nothing
 // in the AST to decrypt.
 func Decrypt(node ast.Node, decrypter Decrypter) error {
+	return DecryptFunc(node, decrypter, nil)
+}
+
+// DecryptFunc is like Decrypt, but calls fn with each literal value that
+// has been decrypted. This allows the caller to distinguish values that
+// were encrypted in the configuration file from values that were stored
+// in clear text. If fn is nil it is not called.
+func DecryptFunc(node ast.Node, decrypter Decrypter, fn func(lit *ast.LiteralType)) error {
 	walker := decryptionWalker{
 		decrypter: decrypter,
+		fn:        fn,
 	}
 
 	ast.Walk(node, walker.Walk)
@@ -44,6 +53,7 @@ func Decrypt(node ast.Node, decrypter Decrypter) error {
 
 type decryptionWalker struct {
 	decrypter Decrypter
+	fn        func(lit *ast.LiteralType)
 	err       error
 }
 
@@ -144,5 +154,8 @@ func (w *decryptionWalker) Walk(node ast.Node) (newNode ast.Node, keepWalking bo
 
 	objectItem.Val = newVal
 	objectItem.Assign = valueLiteralType.Token.Pos
+	if w.fn != nil {
+		w.fn(newVal)
+	}
 	return
 }
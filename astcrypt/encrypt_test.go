@@ -5,11 +5,13 @@ import (
 	"io/ioutil"
 	"log"
 	"path/filepath"
+	"reflect"
 	"regexp"
 	"strings"
 	"testing"
 
 	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
 	"github.com/hashicorp/hcl/hcl/printer"
 )
 
@@ -144,6 +146,29 @@ func TestDecrypt(t *testing.T) {
 	}
 }
 
+func TestDecryptFunc(t *testing.T) {
+	cipherBytes, err := ioutil.ReadFile(filepath.Join(testdataDir, "test01-cipher.hcl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	node, err := hcl.ParseBytes(cipherBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	err = DecryptFunc(node, &testEncryptor{}, func(lit *ast.LiteralType) {
+		got = append(got, lit.Token.Value().(string))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"oltp_password", "mis_password", "fried eggs and ham"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+}
+
 func compareStrings(t *testing.T, name string, got, want string) bool {
 	wsRE := regexp.MustCompile(`\s+`)
 	got = strings.TrimSpace(got)
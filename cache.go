@@ -0,0 +1,161 @@
+package hclconfig
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/hclconfig/download"
+)
+
+// cacheEntry is the metadata stored in the cache directory alongside
+// the body of a downloaded file.
+type cacheEntry struct {
+	Location     string    `json:"location"`
+	ETag         string    `json:"etag,omitempty"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// GetWithFallbackContext is like GetWithFallback, but cancelling the context
+// aborts any download or key decryption that is in progress.
+func (l *Loader) GetWithFallbackContext(ctx context.Context, location string, fallbacks ...string) (*File, error) {
+	locations := append([]string{location}, fallbacks...)
+	var firstErr error
+	for _, location := range locations {
+		d, err := l.downloader().Get(ctx, location)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, err
+			}
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		return l.load(ctx, location, d, false)
+	}
+
+	if l.CacheDir != "" {
+		for _, location := range locations {
+			d, err := l.readCache(location)
+			if err != nil {
+				continue
+			}
+			return l.load(ctx, location, d, true)
+		}
+	}
+	return nil, firstErr
+}
+
+// GetWithFallback is like Get, but if the file cannot be downloaded from
+// location, each of the fallback locations is tried in order. If every
+// location fails and the loader has a cache directory, the cached version
+// of the first location with one is used. If no location succeeds, the
+// error for the first location is returned.
+func (l *Loader) GetWithFallback(location string, fallbacks ...string) (*File, error) {
+	return l.GetWithFallbackContext(context.Background(), location, fallbacks...)
+}
+
+// downloadOrCache downloads the file at location, or reads it from the
+// cache directory if it cannot be downloaded. It returns true if the
+// file was read from the cache. The file is not stored in the cache, as
+// that is only done once the file has been loaded successfully.
+func (l *Loader) downloadOrCache(ctx context.Context, location string) (*download.File, bool, error) {
+	d, err := l.downloader().Get(ctx, location)
+	if err == nil || l.CacheDir == "" || ctx.Err() != nil {
+		return d, false, err
+	}
+	if cached, cacheErr := l.readCache(location); cacheErr == nil {
+		return cached, true, nil
+	}
+	return nil, false, err
+}
+
+// cachePath returns the path of the cache files for location, without
+// the file extension. Any URL credentials are ignored, so that they are
+// not stored in the cache directory.
+func (l *Loader) cachePath(location string) string {
+	sum := sha256.Sum256([]byte(download.StripCredentials(location)))
+	return filepath.Join(l.CacheDir, hex.EncodeToString(sum[:]))
+}
+
+// writeCache stores the raw body of the downloaded file in the cache
+// directory, along with its ETag and last modified time. It is called
+// once the file has been parsed and decrypted, so that a corrupt
+// download does not replace the last good version. The body is
+// stored as downloaded, so any secrets remain encrypted. Sensitive files,
+// whose body has been decrypted by the server, are never cached. Errors
+// are ignored, as the cache is only used when downloads fail.
+func (l *Loader) writeCache(d *download.File) {
+	if l.CacheDir == "" || d.Body == nil || d.Sensitive {
+		return
+	}
+	meta, err := json.Marshal(cacheEntry{
+		Location:     download.StripCredentials(d.Location),
+		ETag:         d.ETag,
+		LastModified: d.LastModified,
+	})
+	if err != nil {
+		return
+	}
+	if err := os.MkdirAll(l.CacheDir, 0700); err != nil {
+		return
+	}
+	path := l.cachePath(d.Location)
+	if writeFileAtomic(path+".body", d.Body) == nil {
+		writeFileAtomic(path+".json", meta)
+	}
+}
+
+// readCache returns the cached version of the file at location.
+func (l *Loader) readCache(location string) (*download.File, error) {
+	path := l.cachePath(location)
+	meta, err := ioutil.ReadFile(path + ".json")
+	if err != nil {
+		return nil, err
+	}
+	var entry cacheEntry
+	if err := json.Unmarshal(meta, &entry); err != nil {
+		return nil, err
+	}
+	if entry.Location != download.StripCredentials(location) {
+		return nil, errors.New("cache entry does not match location").With(
+			"location", download.RedactLocation(location),
+		)
+	}
+	body, err := ioutil.ReadFile(path + ".body")
+	if err != nil {
+		return nil, err
+	}
+	return &download.File{
+		Location:     location,
+		Body:         body,
+		ETag:         entry.ETag,
+		LastModified: entry.LastModified,
+	}, nil
+}
+
+// writeFileAtomic writes a file by renaming a temporary file, so that
+// a partially written file is never read.
+func writeFileAtomic(filename string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
@@ -0,0 +1,257 @@
+package hclconfig
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/jjeffery/hclconfig/download"
+)
+
+func TestGetWithFallback(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fallback := filepath.Join(dir, "fallback.hcl")
+	if err := ioutil.WriteFile(fallback, []byte(`name = "fallback"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	missing := filepath.Join(dir, "missing.hcl")
+	file, err := GetWithFallback(missing, filepath.Join(dir, "also-missing.hcl"), fallback)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := file.Location, fallback; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+	if file.FromCache {
+		t.Error("got FromCache=true, want false")
+	}
+
+	_, err = GetWithFallback(missing, filepath.Join(dir, "also-missing.hcl"))
+	if err == nil || !strings.Contains(err.Error(), "missing.hcl") {
+		t.Errorf("got=%v, want error for first location", err)
+	}
+}
+
+func TestLoaderCacheDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	text := encryptConfig(t, `
+		encryption {
+			test = true
+		}
+		database {
+			password = "s3cret"
+		}
+	`)
+	available := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(text))
+	}))
+	defer server.Close()
+
+	loader := &Loader{
+		KeyProviders: []KeyProvider{testKeyProvider},
+		CacheDir:     filepath.Join(dir, "cache"),
+	}
+	location := server.URL + "/config.hcl"
+	file, err := loader.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.FromCache {
+		t.Error("got FromCache=true, want false")
+	}
+
+	// the cache contains the encrypted body
+	names, err := filepath.Glob(filepath.Join(loader.CacheDir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(names), 2; got != want {
+		t.Fatalf("got=%d cache files, want=%d", got, want)
+	}
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "s3cret") {
+			t.Errorf("%s: contains decrypted value", name)
+		}
+	}
+
+	available = false
+	file, err = loader.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !file.FromCache {
+		t.Error("got FromCache=false, want true")
+	}
+	if got, want := file.Etag, `"v1"`; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+	if got, err := file.GetString("database.password"); got != "s3cret" {
+		t.Errorf("got=%q, err=%v", got, err)
+	}
+
+	// without a cache the download fails
+	if _, err := (&Loader{}).Get(location); err == nil {
+		t.Error("got nil, want error")
+	}
+}
+
+func TestLoaderCacheDirSensitive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	download.RegisterScheme("test-secret", &memFetcher{version: 1, sensitive: true})
+	defer download.RegisterScheme("test-secret", nil)
+
+	loader := &Loader{CacheDir: filepath.Join(dir, "cache")}
+	if _, err := loader.Get("test-secret://app.hcl"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the body was decrypted by the server, so it is not cached
+	names, err := filepath.Glob(filepath.Join(loader.CacheDir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(names) != 0 {
+		t.Errorf("got %v, want no cache files", names)
+	}
+}
+
+func TestLoaderCacheDirCredentials(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	available := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !available {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`value = 1`))
+	}))
+	defer server.Close()
+
+	loader := &Loader{CacheDir: filepath.Join(dir, "cache")}
+	location := strings.Replace(server.URL, "http://", "http://user:pa55word@", 1) + "/config.hcl"
+	if _, err := loader.Get(location); err != nil {
+		t.Fatal(err)
+	}
+
+	// the credentials are not stored in the cache
+	names, err := filepath.Glob(filepath.Join(loader.CacheDir, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(string(data), "pa55word") {
+			t.Errorf("%s: contains credentials", name)
+		}
+	}
+
+	available = false
+	file, err := loader.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !file.FromCache {
+		t.Error("got FromCache=false, want true")
+	}
+}
+
+func TestLoaderCacheDirCorrupt(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	var mu sync.Mutex
+	bodies := map[string]string{
+		"/main.hcl":   `include = "common.hcl"` + "\n" + `value = 1`,
+		"/common.hcl": `common = 1`,
+	}
+	setBody := func(path, body string) {
+		mu.Lock()
+		defer mu.Unlock()
+		bodies[path] = body
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		body := bodies[r.URL.Path]
+		mu.Unlock()
+		if body == "" {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	loader := &Loader{CacheDir: filepath.Join(dir, "cache"), Retry: download.NoRetry}
+	location := server.URL + "/main.hcl"
+	if _, err := loader.Get(location); err != nil {
+		t.Fatal(err)
+	}
+
+	// truncated uploads do not replace the cached versions
+	setBody("/main.hcl", `include = "common.hcl"`+"\n"+`value = "unterminated`)
+	if _, err := loader.Get(location); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	setBody("/main.hcl", `include = "common.hcl"`+"\n"+`value = 2`)
+	setBody("/common.hcl", `common = "unterminated`)
+	if _, err := loader.Get(location); err == nil {
+		t.Fatal("got nil, want error")
+	}
+
+	setBody("/main.hcl", "")
+	setBody("/common.hcl", "")
+	file, err := loader.Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !file.FromCache {
+		t.Error("got FromCache=false, want true")
+	}
+	for path, want := range map[string]int{"value": 1, "common": 1} {
+		if got, err := file.GetInt(path); got != want {
+			t.Errorf("%s: got=%d, want=%d, err=%v", path, got, want, err)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"io"
 	"os"
 
@@ -18,27 +19,22 @@ func decryptFile(location string, inplace bool) error {
 	if err != nil {
 		return err
 	}
-	if inplace && !d.IsLocal {
-		return errors.New("cannot write to non-local config file").With(
-			"location", location,
-		)
-	}
 	file, err := hcl.ParseBytes(d.Body)
 	if err != nil {
 		return errors.Wrap(err).With(
-			"location", location,
+			"location", download.RedactLocation(location),
 		)
 	}
 	decrypter, err := amzn.NewKey(file)
 	if err != nil {
 		return errors.Wrap(err).With(
-			"location", location,
+			"location", download.RedactLocation(location),
 		)
 	}
 	if err = astcrypt.Decrypt(file, decrypter); err != nil {
 		return err
 	}
-	if err := printNode(file, inplace, location); err != nil {
+	if err := printNode(file, inplace, d); err != nil {
 		return err
 	}
 	return nil
@@ -50,55 +46,56 @@ func encryptFile(location string, inplace bool, keywords []string, values []stri
 	if err != nil {
 		return err
 	}
-	if inplace && !d.IsLocal {
-		return errors.New("cannot write to non-local config file").With(
-			"location", location,
-		)
-	}
 	file, err := hcl.ParseBytes(d.Body)
 	if err != nil {
 		return errors.Wrap(err).With(
-			"location", location,
+			"location", download.RedactLocation(location),
 		)
 	}
 	encrypter, err := amzn.NewKey(file)
 	if err != nil {
 		return errors.Wrap(err).With(
-			"location", location,
+			"location", download.RedactLocation(location),
 		)
 	}
 	if err = astcrypt.Encrypt(file, encrypter, keywords, values); err != nil {
 		return err
 	}
 
-	if err := printNode(file, inplace, location); err != nil {
+	if err := printNode(file, inplace, d); err != nil {
 		return err
 	}
 	return nil
 }
 
-func printNode(node ast.Node, inplace bool, location string) error {
-	var out io.WriteCloser
-	var err error
+// printNode prints the node to standard output or, if inplace is true,
+// writes it back to the location of d. The file is only written if it
+// has not been modified since d was downloaded.
+func printNode(node ast.Node, inplace bool, d *download.File) error {
+	var buf bytes.Buffer
+	var out io.Writer = os.Stdout
 	if inplace {
-		out, err = os.Create(location)
-		if err != nil {
-			return err
-		}
-		defer out.Close()
-	} else {
-		out = os.Stdout
+		out = &buf
 	}
 
 	hclPrinter := printer.Config{
 		SpacesWidth: 4,
 	}
 
-	if err = hclPrinter.Fprint(out, node); err != nil {
+	if err := hclPrinter.Fprint(out, node); err != nil {
 		return errors.Wrap(err, "cannot format HCL").With(
-			"location", location,
+			"location", download.RedactLocation(d.Location),
 		)
 	}
 
+	if inplace {
+		if _, err := download.Put(d.Location, buf.Bytes(), d); err != nil {
+			if errors.Cause(err) == download.ErrConflict {
+				return errors.Wrap(err, "file was modified while it was being updated, try again")
+			}
+			return err
+		}
+	}
+
 	return nil
 }
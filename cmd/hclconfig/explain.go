@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/jjeffery/hclconfig"
+)
+
+func explainFile(location string, paths []string) error {
+	file, err := hclconfig.Get(location)
+	if err != nil {
+		return err
+	}
+
+	var origins []*hclconfig.Origin
+	if len(paths) == 0 {
+		origins = file.Origins()
+	} else {
+		for _, path := range paths {
+			origin, err := file.Origin(path)
+			if err != nil {
+				return err
+			}
+			origins = append(origins, origin)
+		}
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, origin := range origins {
+		value := "[redacted]"
+		if !origin.Decrypted {
+			var v interface{}
+			if err := file.DecodePath(origin.Path, &v); err != nil {
+				return err
+			}
+			value = formatValue(v)
+		}
+		fmt.Fprintf(w, "%s = %s\t%s\n", origin.Path, value, origin)
+	}
+	return w.Flush()
+}
+
+// formatValue formats a decoded value for display.
+func formatValue(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return fmt.Sprintf("%q", v)
+	case []interface{}:
+		return "[]"
+	case map[string]interface{}, []map[string]interface{}:
+		return "{}"
+	}
+	return fmt.Sprint(v)
+}
@@ -51,6 +51,7 @@ func rootCommand() *cobra.Command {
 	cmd.AddCommand(encryptCommand())
 	cmd.AddCommand(decryptCommand())
 	cmd.AddCommand(generateCommand())
+	cmd.AddCommand(explainCommand())
 	return cmd
 }
 
@@ -62,7 +63,8 @@ func decryptCommand() *cobra.Command {
 	
 	The decrypted file is written to standard output, unless the --inplace
 	flag is specified, in which case it will overwrite the existing file.
-	This only works for local files.
+	The file is only overwritten if it has not been modified since it
+	was read.
 	`
 	var inplace bool
 	cmd := &cobra.Command{
@@ -91,7 +93,8 @@ The location can be a HTTP(S) URL, and S3 URL or a local file.
 
 The encrypted file is written to standard output, unless the --inplace
 flag is specified, in which case it will overwrite the existing file.
-This only works for local files.
+The file is only overwritten if it has not been modified since it
+was read.
 `
 	keywords := []string{
 		"password",
@@ -121,6 +124,31 @@ This only works for local files.
 	return cmd
 }
 
+func explainCommand() *cobra.Command {
+	const long = `
+Reads the file at location and shows where each value came from:
+the location of the file, the line and column, and whether the
+value was encrypted. This is useful for files that include other
+files, or that contain environment blocks.
+
+If paths are specified, only the values at those paths are shown,
+eg "database.hostname" or "servers[0]". Encrypted values are redacted.
+`
+	cmd := &cobra.Command{
+		Short: "show where values in HCL file came from",
+		Use:   "explain <location> [path...]",
+		Long:  long,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				fmt.Println("expected file name")
+				return errUsagePrinted
+			}
+			return explainFile(args[0], args[1:])
+		},
+	}
+	return cmd
+}
+
 func requireOneFilename(cmd *cobra.Command, args []string) error {
 	if len(args) == 0 {
 		fmt.Println("expected file name")
@@ -0,0 +1,228 @@
+package hclconfig
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/printer"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/jjeffery/hclconfig/download"
+	"github.com/jjeffery/hclconfig/encryption"
+)
+
+// DebugHandler returns an HTTP handler that displays the configuration
+// file returned by current, for debugging a running program. The current
+// function is called for each request, so it can return the latest
+// version of a file that is being watched, eg Live.File.
+//
+// The handler displays the location, ETag, last modified time and last
+// refresh time of the file, followed by its decrypted contents as HCL.
+// If the request has a "format=json" query parameter, or accepts
+// "application/json", the file is displayed as JSON instead.
+//
+// Every value that was encrypted in the configuration file is replaced
+// with a redaction marker containing a short fingerprint of the value,
+// eg "[redacted hmac:1a2b3c4d]". The fingerprint is an HMAC-SHA256 keyed
+// by the data key of the file, so instances that share the data key can
+// compare the values they use, but the values cannot be recovered by
+// hashing candidate values.
+//
+// The handler does not perform any authorization.
+func DebugHandler(current func() *File) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f := current()
+		if f == nil {
+			http.Error(w, "no configuration file loaded", http.StatusServiceUnavailable)
+			return
+		}
+		if r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json") {
+			writeDebugJSON(w, f)
+			return
+		}
+		writeDebugHCL(w, f)
+	})
+}
+
+func writeDebugHCL(w http.ResponseWriter, f *File) {
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// location:      %s\n", download.RedactLocation(f.Location))
+	fmt.Fprintf(&buf, "// etag:          %s\n", f.Etag)
+	fmt.Fprintf(&buf, "// last modified: %s\n", formatTime(f.LastModified))
+	fmt.Fprintf(&buf, "// refreshed:     %s\n\n", formatTime(f.RefreshedAt()))
+	node := f.redact(objectList(f.Contents.Node))
+	if err := printer.Fprint(&buf, node); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+func writeDebugJSON(w http.ResponseWriter, f *File) {
+	v := struct {
+		Location     string                 `json:"location"`
+		Etag         string                 `json:"etag,omitempty"`
+		LastModified string                 `json:"lastModified,omitempty"`
+		Refreshed    string                 `json:"refreshed,omitempty"`
+		Contents     map[string]interface{} `json:"contents"`
+	}{
+		Location:     download.RedactLocation(f.Location),
+		Etag:         f.Etag,
+		LastModified: formatTime(f.LastModified),
+		Refreshed:    formatTime(f.RefreshedAt()),
+		Contents:     f.jsonObject(objectList(f.Contents.Node)),
+	}
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// formatTime returns the time in RFC 3339 format, or an empty
+// string for the zero time.
+func formatTime(t time.Time) string {
+	if t.IsZero() || t.UnixNano() == 0 {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// fingerprintLabel is used to derive the fingerprint key of a file
+// from its data key, so that the data key is not used directly.
+const fingerprintLabel = "hclconfig redaction fingerprint"
+
+// newFingerprintKey returns the key used to fingerprint the decrypted
+// values of a file with the data key.
+func newFingerprintKey(key encryption.Key) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(fingerprintLabel))
+	return mac.Sum(nil)
+}
+
+// fingerprint returns the redaction marker for a decrypted value.
+func (f *File) fingerprint(lit *ast.LiteralType) string {
+	text := lit.Token.Text
+	if s, ok := lit.Token.Value().(string); ok {
+		text = s
+	}
+	mac := hmac.New(sha256.New, f.fingerprintKeyOf(lit))
+	mac.Write([]byte(text))
+	return "[redacted hmac:" + hex.EncodeToString(mac.Sum(nil)[:4]) + "]"
+}
+
+// fingerprintKeyOf returns the fingerprint key of the file that the
+// decrypted value came from, which is one of the layers of a merged file.
+func (f *File) fingerprintKeyOf(lit *ast.LiteralType) []byte {
+	for _, layer := range f.Layers {
+		if layer.decrypted[lit] {
+			return layer.fingerprintKeyOf(lit)
+		}
+	}
+	return f.fingerprintKey
+}
+
+// redact returns a copy of the node in which every decrypted value is
+// replaced with its redaction marker. The contents of the file are
+// shared with other goroutines, so they are never modified.
+func (f *File) redact(node ast.Node) ast.Node {
+	switch n := node.(type) {
+	case *ast.ObjectList:
+		list := &ast.ObjectList{Items: make([]*ast.ObjectItem, len(n.Items))}
+		for i, item := range n.Items {
+			list.Items[i] = f.redact(item).(*ast.ObjectItem)
+		}
+		return list
+	case *ast.ObjectItem:
+		item := *n
+		item.Val = f.redact(n.Val)
+		return &item
+	case *ast.ObjectType:
+		obj := *n
+		obj.List = f.redact(n.List).(*ast.ObjectList)
+		return &obj
+	case *ast.ListType:
+		list := *n
+		list.List = make([]ast.Node, len(n.List))
+		for i, elem := range n.List {
+			list.List[i] = f.redact(elem)
+		}
+		return &list
+	case *ast.LiteralType:
+		if !f.decrypted[n] {
+			return n
+		}
+		return &ast.LiteralType{
+			Token: token.Token{
+				Type: token.STRING,
+				Pos:  n.Token.Pos,
+				Text: strconv.Quote(f.fingerprint(n)),
+				JSON: true,
+			},
+			LineComment: n.LineComment,
+		}
+	}
+	return node
+}
+
+// jsonObject returns the contents of the list as a value that can be
+// marshaled as JSON. A key with more than one value, such as a repeated
+// block, has a JSON array value. Decrypted values are redacted.
+func (f *File) jsonObject(list *ast.ObjectList) map[string]interface{} {
+	m := make(map[string]interface{})
+	done := make(map[string]bool)
+	for _, item := range list.Items {
+		if len(item.Keys) == 0 {
+			continue
+		}
+		key := keyText(item.Keys[0])
+		if done[strings.ToLower(key)] {
+			continue
+		}
+		done[strings.ToLower(key)] = true
+		filter := list.Filter(key)
+		var values []interface{}
+		if children := filter.Children(); len(children.Items) > 0 {
+			values = append(values, f.jsonObject(children))
+		}
+		for _, elem := range filter.Elem().Items {
+			values = append(values, f.jsonValue(elem.Val))
+		}
+		if len(values) == 1 {
+			m[key] = values[0]
+		} else {
+			m[key] = values
+		}
+	}
+	return m
+}
+
+func (f *File) jsonValue(node ast.Node) interface{} {
+	switch n := node.(type) {
+	case *ast.ObjectType:
+		return f.jsonObject(n.List)
+	case *ast.ListType:
+		values := make([]interface{}, len(n.List))
+		for i, elem := range n.List {
+			values[i] = f.jsonValue(elem)
+		}
+		return values
+	case *ast.LiteralType:
+		if f.decrypted[n] {
+			return f.fingerprint(n)
+		}
+		return n.Token.Value()
+	}
+	return nil
+}
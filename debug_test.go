@@ -0,0 +1,148 @@
+package hclconfig
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/jjeffery/hclconfig/encryption"
+)
+
+func TestDebugHandler(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.hcl")
+	writeConfig(t, filename, encryptConfig(t, `
+		encryption {
+			test = true
+		}
+		database {
+			hostname = "db.example.com"
+			password = "s3cret"
+		}
+		listener {
+			port = 80
+		}
+		listener {
+			port = 443
+		}
+	`), time.Date(2017, 6, 1, 10, 0, 0, 0, time.UTC))
+
+	loader := &Loader{
+		KeyProviders: []KeyProvider{testKeyProvider},
+	}
+	file, err := loader.Get(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := DebugHandler(func() *File { return file })
+
+	// hmac-sha256("s3cret") keyed by the fingerprint key of testKey
+	const marker = "[redacted hmac:2acc4716]"
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/debug/config", nil))
+	body := w.Body.String()
+	for _, want := range []string{
+		"// location:      " + filename,
+		"// last modified: 2017-06-01T10:00:00Z",
+		`hostname = "db.example.com"`,
+		`password = "` + marker + `"`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("missing %q in:\n%s", want, body)
+		}
+	}
+	if strings.Contains(body, "s3cret") {
+		t.Errorf("secret displayed:\n%s", body)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest("GET", "/debug/config?format=json", nil))
+	var v struct {
+		Location     string
+		LastModified string
+		Refreshed    string
+		Contents     struct {
+			Database struct {
+				Hostname string
+				Password string
+			}
+			Listener []struct {
+				Port int
+			}
+		}
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil {
+		t.Fatalf("%v: %s", err, w.Body.String())
+	}
+	if got, want := v.Contents.Database.Password, marker; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+	if got, want := len(v.Contents.Listener), 2; got != want {
+		t.Errorf("got=%d, want=%d", got, want)
+	}
+	if v.Location != filename || v.LastModified == "" || v.Refreshed == "" {
+		t.Errorf("got=%+v", v)
+	}
+
+	// the contents of the file are not modified
+	if got, err := file.GetString("database.password"); got != "s3cret" {
+		t.Errorf("got=%q, err=%v", got, err)
+	}
+}
+
+func TestDebugHandlerRedactsLocation(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`value = 1`))
+	}))
+	defer server.Close()
+
+	location := strings.Replace(server.URL, "http://", "http://T0KEN@", 1) + "/config.hcl"
+	file, err := Get(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := DebugHandler(func() *File { return file })
+	for _, target := range []string{"/debug/config", "/debug/config?format=json"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		if body := w.Body.String(); strings.Contains(body, "T0KEN") {
+			t.Errorf("%s: location not redacted:\n%s", target, body)
+		}
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	lit := &ast.LiteralType{Token: token.Token{Type: token.STRING, Text: `"s3cret"`}}
+	otherKey := make(encryption.Key, len(testKey))
+	f1 := &File{fingerprintKey: newFingerprintKey(testKey)}
+	f2 := &File{fingerprintKey: newFingerprintKey(testKey)}
+	f3 := &File{fingerprintKey: newFingerprintKey(otherKey)}
+
+	if got, want := f1.fingerprint(lit), f2.fingerprint(lit); got != want {
+		t.Errorf("same key: got=%q, want=%q", got, want)
+	}
+	if f1.fingerprint(lit) == f3.fingerprint(lit) {
+		t.Errorf("different keys: got same fingerprint %q", f1.fingerprint(lit))
+	}
+
+	// a layer's values are fingerprinted with the layer's key
+	f3.decrypted = map[*ast.LiteralType]bool{lit: true}
+	merged := &File{Layers: []*File{f1, f3}}
+	if got, want := merged.fingerprint(lit), f3.fingerprint(lit); got != want {
+		t.Errorf("layers: got=%q, want=%q", got, want)
+	}
+}
@@ -0,0 +1,732 @@
+package hclconfig
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/hclconfig/download"
+)
+
+// DecodeHook converts a value in a configuration file into a value of
+// the type that the hook is registered for. The value passed to the hook
+// is a string, int64, float64 or bool. The value returned by the hook must
+// be assignable or convertible to the registered type.
+type DecodeHook func(value interface{}) (interface{}, error)
+
+var (
+	decodeHooks = struct {
+		sync.RWMutex
+		m map[reflect.Type]DecodeHook
+	}{
+		m: make(map[reflect.Type]DecodeHook),
+	}
+
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	nodeType            = reflect.TypeOf((*ast.Node)(nil)).Elem()
+)
+
+func init() {
+	// url.URL does not implement encoding.TextUnmarshaler
+	RegisterDecodeHook(reflect.TypeOf(url.URL{}), func(value interface{}) (interface{}, error) {
+		s, ok := value.(string)
+		if !ok {
+			return nil, errors.New("expected a string")
+		}
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, err
+		}
+		return *u, nil
+	})
+}
+
+// RegisterDecodeHook registers a hook that File.Decode uses to decode
+// values of type t. A hook registered for a type takes precedence over
+// the built-in handling of time.Duration and encoding.TextUnmarshaler.
+// Registering a nil hook removes any hook for the type.
+//
+// RegisterDecodeHook is typically called during program initialization.
+func RegisterDecodeHook(t reflect.Type, hook DecodeHook) {
+	decodeHooks.Lock()
+	defer decodeHooks.Unlock()
+	if hook == nil {
+		delete(decodeHooks.m, t)
+		return
+	}
+	decodeHooks.m[t] = hook
+}
+
+func lookupDecodeHook(t reflect.Type) DecodeHook {
+	decodeHooks.RLock()
+	defer decodeHooks.RUnlock()
+	return decodeHooks.m[t]
+}
+
+// decode decodes the contents of the file into v. If strict is true,
+// keys in the file that do not match any field are reported as errors.
+func (f *File) decode(v interface{}, strict bool) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return errors.New("result must be a non-nil pointer").With(
+			"location", download.RedactLocation(f.Location),
+		)
+	}
+
+	d := decoder{decrypted: f.decrypted}
+	d.decode("", f.Contents.Node, val.Elem())
+	errs := d.errs
+	if strict {
+		errs = append(errs, d.unused...)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	errs.setDefaultLocation(f.Location)
+	return errs
+}
+
+// decoder decodes the AST of a configuration file into a Go value.
+// It follows the same rules as hcl.DecodeObject, with the addition of
+// decode hooks, time.Duration, encoding.TextUnmarshaler and Secret.
+// Rather than stopping at the first error, it reports every value
+// that cannot be decoded.
+type decoder struct {
+	decrypted map[*ast.LiteralType]bool
+	stack     []reflect.Kind
+	errs      KeyErrors
+	unused    KeyErrors
+
+	// pos is the position of the block being decoded, and is used
+	// to report problems with keys that are missing from the block
+	pos token.Pos
+}
+
+func (d *decoder) errorf(path string, node ast.Node, format string, args ...interface{}) {
+	d.errs = append(d.errs, newKeyError(path, node, fmt.Sprintf(format, args...)))
+}
+
+func (d *decoder) decode(path string, node ast.Node, result reflect.Value) {
+	k := result
+
+	// If we have an interface with a valid value, we use that
+	// for the check.
+	if result.Kind() == reflect.Interface {
+		elem := result.Elem()
+		if elem.IsValid() {
+			k = elem
+		}
+	}
+
+	// Push current onto stack unless it is an interface.
+	if k.Kind() != reflect.Interface {
+		d.stack = append(d.stack, k.Kind())
+		defer func() {
+			d.stack = d.stack[:len(d.stack)-1]
+		}()
+
+		if d.decodeLiteral(path, node, result) {
+			d.callValidate(path, node, result)
+			return
+		}
+	}
+
+	switch k.Kind() {
+	case reflect.Bool:
+		d.decodeBool(path, node, result)
+	case reflect.Float32, reflect.Float64:
+		d.decodeFloat(path, node, result)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		d.decodeInt(path, node, result)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		d.decodeUint(path, node, result)
+	case reflect.Interface:
+		d.decodeInterface(path, node, result)
+	case reflect.Map:
+		d.decodeMap(path, node, result)
+	case reflect.Ptr:
+		d.decodePtr(path, node, result)
+	case reflect.Slice:
+		d.decodeSlice(path, node, result)
+	case reflect.String:
+		d.decodeString(path, node, result)
+	case reflect.Struct:
+		d.decodeStruct(path, node, result)
+	default:
+		d.errorf(path, node, "unknown kind to decode into: %s", k.Kind())
+		return
+	}
+
+	if result.Kind() != reflect.Interface && result.Kind() != reflect.Ptr {
+		d.callValidate(path, node, result)
+	}
+}
+
+// decodeLiteral handles types that are decoded from a literal value using
+// a decode hook, time.ParseDuration or encoding.TextUnmarshaler. It returns
+// true if the value has been handled.
+func (d *decoder) decodeLiteral(path string, node ast.Node, result reflect.Value) bool {
+	lit, ok := node.(*ast.LiteralType)
+	if !ok {
+		return false
+	}
+	t := result.Type()
+
+	if hook := lookupDecodeHook(t); hook != nil {
+		v, err := hook(lit.Token.Value())
+		if err != nil {
+			d.errorf(path, node, "cannot decode %s: %v", t, err)
+			return true
+		}
+		rv := reflect.ValueOf(v)
+		switch {
+		case !rv.IsValid():
+			result.Set(reflect.Zero(t))
+		case rv.Type().AssignableTo(t):
+			result.Set(rv)
+		case rv.Type().ConvertibleTo(t):
+			result.Set(rv.Convert(t))
+		default:
+			d.errorf(path, node, "decode hook for %s returned %s", t, rv.Type())
+		}
+		return true
+	}
+
+	if t == durationType {
+		if lit.Token.Type != token.STRING && lit.Token.Type != token.HEREDOC {
+			d.errorf(path, node, "duration must be a string, eg \"30s\"")
+			return true
+		}
+		v, err := time.ParseDuration(lit.Token.Value().(string))
+		if err != nil {
+			d.errorf(path, node, "%v", err)
+			return true
+		}
+		result.SetInt(int64(v))
+		return true
+	}
+
+	if reflect.PtrTo(t).Implements(textUnmarshalerType) {
+		text := lit.Token.Text
+		if s, ok := lit.Token.Value().(string); ok {
+			text = s
+		}
+		v := reflect.New(t)
+		if err := v.Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(text)); err != nil {
+			d.errorf(path, node, "cannot decode %s: %v", t, err)
+			return true
+		}
+		result.Set(v.Elem())
+		return true
+	}
+
+	return false
+}
+
+func (d *decoder) decodeBool(path string, node ast.Node, result reflect.Value) {
+	if n, ok := node.(*ast.LiteralType); ok && n.Token.Type == token.BOOL {
+		v, err := strconv.ParseBool(n.Token.Text)
+		if err != nil {
+			d.errorf(path, node, "%v", err)
+			return
+		}
+		result.Set(reflect.ValueOf(v).Convert(result.Type()))
+		return
+	}
+	d.errorf(path, node, "expected a bool")
+}
+
+func (d *decoder) decodeFloat(path string, node ast.Node, result reflect.Value) {
+	if n, ok := node.(*ast.LiteralType); ok {
+		if n.Token.Type == token.FLOAT || n.Token.Type == token.NUMBER {
+			v, err := strconv.ParseFloat(n.Token.Text, 64)
+			if err != nil {
+				d.errorf(path, node, "%v", err)
+				return
+			}
+			result.Set(reflect.ValueOf(v).Convert(result.Type()))
+			return
+		}
+	}
+	d.errorf(path, node, "expected a number")
+}
+
+func (d *decoder) decodeInt(path string, node ast.Node, result reflect.Value) {
+	if n, ok := node.(*ast.LiteralType); ok {
+		var text string
+		switch n.Token.Type {
+		case token.NUMBER:
+			text = n.Token.Text
+		case token.STRING:
+			text = n.Token.Value().(string)
+		default:
+			d.errorf(path, node, "expected an integer")
+			return
+		}
+		v, err := strconv.ParseInt(text, 0, 0)
+		if err != nil {
+			d.errorf(path, node, "%v", err)
+			return
+		}
+		if result.Kind() == reflect.Interface {
+			result.Set(reflect.ValueOf(int(v)))
+			return
+		}
+		if result.OverflowInt(v) {
+			d.errorf(path, node, "value %d overflows %s", v, result.Type())
+			return
+		}
+		result.SetInt(v)
+		return
+	}
+	d.errorf(path, node, "expected an integer")
+}
+
+func (d *decoder) decodeUint(path string, node ast.Node, result reflect.Value) {
+	if n, ok := node.(*ast.LiteralType); ok {
+		var text string
+		switch n.Token.Type {
+		case token.NUMBER:
+			text = n.Token.Text
+		case token.STRING:
+			text = n.Token.Value().(string)
+		default:
+			d.errorf(path, node, "expected an unsigned integer")
+			return
+		}
+		v, err := strconv.ParseUint(text, 0, 0)
+		if err != nil {
+			d.errorf(path, node, "%v", err)
+			return
+		}
+		if result.OverflowUint(v) {
+			d.errorf(path, node, "value %d overflows %s", v, result.Type())
+			return
+		}
+		result.SetUint(v)
+		return
+	}
+	d.errorf(path, node, "expected an unsigned integer")
+}
+
+func (d *decoder) decodeString(path string, node ast.Node, result reflect.Value) {
+	if n, ok := node.(*ast.LiteralType); ok {
+		switch n.Token.Type {
+		case token.NUMBER:
+			result.Set(reflect.ValueOf(n.Token.Text).Convert(result.Type()))
+		case token.STRING, token.HEREDOC:
+			result.Set(reflect.ValueOf(n.Token.Value()).Convert(result.Type()))
+		default:
+			d.errorf(path, node, "expected a string")
+			return
+		}
+		if result.Type() == secretType && !d.decrypted[n] {
+			d.errorf(path, node, "secret is not encrypted")
+		}
+		return
+	}
+	d.errorf(path, node, "expected a string")
+}
+
+func (d *decoder) decodeInterface(path string, node ast.Node, result reflect.Value) {
+	// When we see an ast.Node, we retain the value to enable deferred decoding.
+	if result.Type() == nodeType && result.CanSet() {
+		result.Set(reflect.ValueOf(node))
+		return
+	}
+
+	testNode := node
+	if ot, ok := node.(*ast.ObjectType); ok {
+		testNode = ot.List
+	}
+
+	var set reflect.Value
+	switch n := testNode.(type) {
+	case *ast.ObjectList:
+		// If we're at the root or we're directly within a slice, then we
+		// decode objects into map[string]interface{}, otherwise we decode
+		// them into lists.
+		if len(d.stack) == 0 || d.stack[len(d.stack)-1] == reflect.Slice {
+			set = reflect.ValueOf(make(map[string]interface{}))
+		} else {
+			set = reflect.ValueOf(make([]map[string]interface{}, 0, len(n.Items)))
+		}
+	case *ast.ListType:
+		set = reflect.ValueOf(make([]interface{}, 0))
+	case *ast.LiteralType:
+		switch n.Token.Type {
+		case token.BOOL:
+			set = reflect.New(reflect.TypeOf(false)).Elem()
+		case token.FLOAT:
+			set = reflect.New(reflect.TypeOf(float64(0))).Elem()
+		case token.NUMBER:
+			set = reflect.New(reflect.TypeOf(int(0))).Elem()
+		case token.STRING, token.HEREDOC:
+			set = reflect.New(reflect.TypeOf("")).Elem()
+		default:
+			d.errorf(path, node, "cannot decode into interface: %s", n.Token.Type)
+			return
+		}
+	default:
+		d.errorf(path, node, "cannot decode into interface: %T", node)
+		return
+	}
+
+	// Set the result to what it is supposed to be, then revisit the node
+	// so that we can use the newly instantiated thing and populate it.
+	result.Set(set)
+	d.decode(path, node, result)
+}
+
+func (d *decoder) decodeMap(path string, node ast.Node, result reflect.Value) {
+	if item, ok := node.(*ast.ObjectItem); ok {
+		node = &ast.ObjectList{Items: []*ast.ObjectItem{item}}
+	}
+	if ot, ok := node.(*ast.ObjectType); ok {
+		node = ot.List
+	}
+	n, ok := node.(*ast.ObjectList)
+	if !ok {
+		d.errorf(path, node, "expected an object")
+		return
+	}
+
+	// If we have an interface, then we can address the interface,
+	// but not the map itself, so get the element but set the interface
+	set := result
+	if result.Kind() == reflect.Interface {
+		result = result.Elem()
+	}
+
+	resultType := result.Type()
+	if resultType.Key().Kind() != reflect.String {
+		d.errorf(path, node, "map must have string keys")
+		return
+	}
+
+	resultMap := result
+	if result.IsNil() {
+		resultMap = reflect.MakeMap(resultType)
+	}
+
+	done := make(map[string]bool)
+	for _, item := range n.Items {
+		if item.Val == nil {
+			continue
+		}
+		if len(item.Keys) == 0 {
+			d.errorf(path, item.Val, "map must have string keys")
+			continue
+		}
+
+		keyStr := keyText(item.Keys[0])
+		if done[keyStr] {
+			continue
+		}
+
+		// If there is more than one key, then decode the object list
+		// of all items with this key.
+		var itemVal ast.Node = item.Val
+		if len(item.Keys) > 1 {
+			itemVal = n.Filter(keyStr)
+			done[keyStr] = true
+		}
+
+		key := reflect.ValueOf(keyStr).Convert(resultType.Key())
+		val := reflect.New(resultType.Elem()).Elem()
+		if oldVal := resultMap.MapIndex(key); oldVal.IsValid() {
+			val.Set(oldVal)
+		}
+		d.decode(joinPath(path, keyStr), itemVal, val)
+		resultMap.SetMapIndex(key, val)
+	}
+
+	set.Set(resultMap)
+}
+
+func (d *decoder) decodePtr(path string, node ast.Node, result reflect.Value) {
+	val := reflect.New(result.Type().Elem())
+	d.decode(path, node, val.Elem())
+	result.Set(val)
+}
+
+func (d *decoder) decodeSlice(path string, node ast.Node, result reflect.Value) {
+	// If we have an interface, then we can address the interface,
+	// but not the slice itself, so get the element but set the interface
+	set := result
+	if result.Kind() == reflect.Interface {
+		result = result.Elem()
+	}
+	resultType := result.Type()
+	if result.IsNil() {
+		result = reflect.MakeSlice(resultType, 0, 0)
+	}
+
+	var items []ast.Node
+	switch n := node.(type) {
+	case *ast.ObjectList:
+		for _, item := range n.Items {
+			items = append(items, item)
+		}
+	case *ast.ObjectType:
+		items = []ast.Node{n}
+	case *ast.ListType:
+		items = n.List
+	default:
+		d.errorf(path, node, "expected a list")
+		return
+	}
+
+	for i, item := range items {
+		val := reflect.New(resultType.Elem()).Elem()
+		item := expandObject(item, val)
+		d.decode(fmt.Sprintf("%s[%d]", path, i), item, val)
+		result = reflect.Append(result, val)
+	}
+
+	set.Set(result)
+}
+
+// expandObject detects if an ambiguous JSON object was flattened to a list
+// which should be decoded into a struct, and expands the AST so that it
+// decodes properly.
+func expandObject(node ast.Node, result reflect.Value) ast.Node {
+	item, ok := node.(*ast.ObjectItem)
+	if !ok {
+		return node
+	}
+	elemType := result.Type()
+	if elemType.Kind() == reflect.Ptr {
+		elemType = elemType.Elem()
+	}
+	if elemType.Kind() != reflect.Struct {
+		return node
+	}
+
+	// A list value will have a key and field name. If it had more fields,
+	// it wouldn't have been flattened.
+	if len(item.Keys) != 2 {
+		return node
+	}
+
+	rest := *item
+	rest.Keys = item.Keys[1:]
+	return &ast.ObjectItem{
+		Keys: item.Keys[:1],
+		Val: &ast.ObjectType{
+			List: &ast.ObjectList{
+				Items: []*ast.ObjectItem{&rest},
+			},
+		},
+	}
+}
+
+func (d *decoder) decodeStruct(path string, node ast.Node, result reflect.Value) {
+	outer := d.pos
+	defer func() {
+		d.pos = outer
+	}()
+	pos := outer
+	switch node.(type) {
+	case *ast.ObjectItem, *ast.ObjectType:
+		pos = node.Pos()
+	}
+
+	var item *ast.ObjectItem
+	if it, ok := node.(*ast.ObjectItem); ok {
+		item = it
+		node = it.Val
+	}
+	if ot, ok := node.(*ast.ObjectType); ok {
+		node = ot.List
+	}
+
+	// Handle the special case where the object itself is a literal.
+	if _, ok := node.(*ast.LiteralType); ok && item != nil {
+		node = &ast.ObjectList{Items: []*ast.ObjectItem{item}}
+	}
+
+	list, ok := node.(*ast.ObjectList)
+	if !ok {
+		d.errorf(path, node, "expected an object")
+		return
+	}
+
+	// Compile the list of all the fields that we're going to be decoding
+	// from all the structs, including squashed embedded structs.
+	type field struct {
+		field reflect.StructField
+		val   reflect.Value
+	}
+	var fields []field
+	structs := []reflect.Value{result}
+	for len(structs) > 0 {
+		structVal := structs[0]
+		structs = structs[1:]
+		structType := structVal.Type()
+		for i := 0; i < structType.NumField(); i++ {
+			fieldType := structType.Field(i)
+			tagParts := strings.Split(fieldType.Tag.Get("hcl"), ",")
+			if tagParts[0] == "-" {
+				continue
+			}
+			if fieldType.Anonymous && hasTag(tagParts[1:], "squash") {
+				if fieldType.Type.Kind() != reflect.Struct {
+					d.errorf(path, node, "%s: unsupported type to squash: %s", fieldType.Name, fieldType.Type.Kind())
+					continue
+				}
+				structs = append(structs, structVal.Field(i))
+				continue
+			}
+			fields = append(fields, field{fieldType, structVal.Field(i)})
+		}
+	}
+
+	usedKeys := make(map[string]bool)
+	var decodedFields []string
+	var decodedFieldsVal, unusedKeysVal []reflect.Value
+	for _, f := range fields {
+		field, fieldValue := f.field, f.val
+
+		// If we can't set the field, then it is unexported or something,
+		// and we just continue onwards.
+		if !fieldValue.CanSet() {
+			continue
+		}
+
+		fieldName := field.Name
+		tagParts := strings.SplitN(field.Tag.Get("hcl"), ",", 2)
+		if len(tagParts) >= 2 {
+			switch tagParts[1] {
+			case "decodedFields":
+				decodedFieldsVal = append(decodedFieldsVal, fieldValue)
+				continue
+			case "key":
+				if item == nil || len(item.Keys) == 0 {
+					d.errorf(path, node, "%s asked for 'key', impossible", fieldName)
+					continue
+				}
+				fieldValue.SetString(keyText(item.Keys[0]))
+				continue
+			case "unusedKeys":
+				unusedKeysVal = append(unusedKeysVal, fieldValue)
+				continue
+			}
+		}
+		if tagParts[0] != "" {
+			fieldName = tagParts[0]
+		}
+
+		// Determine the element we'll use to decode. If it is a single
+		// match (only object with the field), then we decode it exactly.
+		// If it is a prefix match, then we decode the matches.
+		filter := list.Filter(fieldName)
+		if len(filter.Items) == 0 {
+			key := fieldName
+			if tagParts[0] == "" {
+				key = strings.ToLower(key)
+			}
+			if d.decodeMissing(joinPath(path, key), field, fieldValue, pos) {
+				d.validateField(joinPath(path, key), field, fieldValue, pos)
+			}
+			continue
+		}
+
+		usedKeys[strings.ToLower(fieldName)] = true
+		keyItem := matchingItem(list, fieldName)
+		fieldPath := joinPath(path, keyText(keyItem.Keys[0]))
+		d.decodeFilter(fieldPath, filter, fieldValue)
+		d.validateField(fieldPath, field, fieldValue, keyItem.Pos())
+
+		decodedFields = append(decodedFields, field.Name)
+	}
+
+	if len(decodedFieldsVal) > 0 {
+		sort.Strings(decodedFields)
+		for _, v := range decodedFieldsVal {
+			v.Set(reflect.ValueOf(decodedFields))
+		}
+	}
+
+	var unusedKeys []string
+	for _, it := range list.Items {
+		if len(it.Keys) == 0 {
+			continue
+		}
+		key := keyText(it.Keys[0])
+		if usedKeys[strings.ToLower(key)] {
+			continue
+		}
+		if path == "" && key == "encryption" {
+			// the encryption block is used by the loader
+			continue
+		}
+		unusedKeys = append(unusedKeys, key)
+		if len(unusedKeysVal) == 0 {
+			d.unused = append(d.unused, newKeyError(joinPath(path, key), it, "unused key"))
+		}
+	}
+	for _, v := range unusedKeysVal {
+		v.Set(reflect.ValueOf(unusedKeys))
+	}
+}
+
+// decodeFilter decodes the items that match a key, as returned by
+// ast.ObjectList.Filter, into result. If an item is a prefix match,
+// such as service "web" {...} for the key "service", then the prefix
+// matches are decoded together. Otherwise each match is decoded in turn.
+func (d *decoder) decodeFilter(path string, filter *ast.ObjectList, result reflect.Value) {
+	if prefixMatches := filter.Children(); len(prefixMatches.Items) > 0 {
+		d.pos = prefixMatches.Items[0].Val.Pos()
+		d.decode(path, prefixMatches, result)
+	}
+	for _, match := range filter.Elem().Items {
+		d.pos = match.Val.Pos()
+		var node ast.Node = match.Val
+		if ot, ok := node.(*ast.ObjectType); ok {
+			node = &ast.ObjectList{Items: ot.List.Items}
+		}
+		d.decode(path, node, result)
+	}
+}
+
+// matchingItem returns the first item in the list with a key that
+// matches name, or nil if there is no such item.
+func matchingItem(list *ast.ObjectList, name string) *ast.ObjectItem {
+	for _, item := range list.Items {
+		if len(item.Keys) > 0 && strings.EqualFold(keyText(item.Keys[0]), name) {
+			return item
+		}
+	}
+	return nil
+}
+
+// hasTag reports whether the tag options include any of the names.
+func hasTag(options []string, names ...string) bool {
+	for _, option := range options {
+		for _, name := range names {
+			if option == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// joinPath returns the path of the key within the object at path.
+func joinPath(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
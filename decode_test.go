@@ -0,0 +1,328 @@
+package hclconfig
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl"
+)
+
+// newTestFile returns a file with the parsed contents of text.
+func newTestFile(t *testing.T, text string) *File {
+	t.Helper()
+	node, err := hcl.ParseString(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &File{
+		Location: "test.hcl",
+		Contents: node,
+	}
+}
+
+func TestDecodeStrict(t *testing.T) {
+	file := newTestFile(t, `
+encryption {
+	kms = "data-key"
+}
+database {
+	hostname = "db.example.com"
+	pasword = "s3cret"
+}
+service "web" {
+	port = 80
+	extra = true
+}
+listener {
+	port = 80
+}
+listener {
+	port = 443
+}
+tags {
+	anything = "goes"
+}
+unknown = 1
+`)
+
+	type service struct {
+		Port int
+	}
+	var config struct {
+		Database struct {
+			Hostname string
+			Password string
+		}
+		Service  map[string]service
+		Listener []struct {
+			Port int
+		}
+		Tags map[string]interface{}
+	}
+
+	err := file.DecodeStrict(&config)
+	errs, ok := err.(KeyErrors)
+	if !ok {
+		t.Fatalf("got %T, want KeyErrors: %v", err, err)
+	}
+	var got []string
+	for _, err := range errs {
+		got = append(got, err.Key)
+		if err.Line == 0 || err.Location != "test.hcl" {
+			t.Errorf("missing position: %v", err)
+		}
+	}
+	want := []string{"database.pasword", "service.web.extra", "unknown"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+	if got, want := errs[0].Line, 7; got != want {
+		t.Errorf("line: got=%d, want=%d", got, want)
+	}
+
+	// Decode ignores unused keys
+	if err := file.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeStrictUnusedKeys(t *testing.T) {
+	file := newTestFile(t, `
+name = "x"
+other = 1
+`)
+	var config struct {
+		Name   string
+		Unused []string `hcl:",unusedKeys"`
+	}
+	if err := file.DecodeStrict(&config); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type logLevel int
+
+func TestDecode(t *testing.T) {
+	RegisterDecodeHook(reflect.TypeOf(logLevel(0)), func(value interface{}) (interface{}, error) {
+		switch value {
+		case "debug":
+			return 1, nil
+		case "info":
+			return 2, nil
+		}
+		return nil, errors.New("unknown log level")
+	})
+	defer RegisterDecodeHook(reflect.TypeOf(logLevel(0)), nil)
+
+	file := newTestFile(t, `
+timeout = "30s"
+address = "10.0.0.1"
+endpoint = "https://example.com/api"
+started = "2017-06-01T10:00:00Z"
+level = "info"
+`)
+	var config struct {
+		Timeout  time.Duration
+		Address  net.IP
+		Endpoint *url.URL
+		Started  time.Time
+		Level    logLevel
+	}
+	if err := file.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Timeout, 30*time.Second; got != want {
+		t.Errorf("timeout: got=%v, want=%v", got, want)
+	}
+	if got, want := config.Address.String(), "10.0.0.1"; got != want {
+		t.Errorf("address: got=%v, want=%v", got, want)
+	}
+	if got, want := config.Endpoint.Host, "example.com"; got != want {
+		t.Errorf("endpoint: got=%v, want=%v", got, want)
+	}
+	if got, want := config.Started.Year(), 2017; got != want {
+		t.Errorf("started: got=%v, want=%v", got, want)
+	}
+	if got, want := config.Level, logLevel(2); got != want {
+		t.Errorf("level: got=%v, want=%v", got, want)
+	}
+}
+
+// TestDecodeMatchesHCL checks that values without decode hooks are
+// decoded the same way as hcl.DecodeObject.
+func TestDecodeMatchesHCL(t *testing.T) {
+	type user struct {
+		Name string `hcl:",key"`
+		X    int
+	}
+	type listener struct {
+		Port int
+	}
+	tests := []struct {
+		text string
+		v    func() interface{}
+	}{
+		{
+			text: `users { alice { x = 1 } bob { x = 2 } }`,
+			v:    func() interface{} { return &struct{ Users []user }{} },
+		},
+		{
+			text: `users "alice" { x = 1 } users "bob" { x = 2 }`,
+			v:    func() interface{} { return &struct{ Users []user }{} },
+		},
+		{
+			text: `listener { port = 80 } listener { port = 443 }`,
+			v:    func() interface{} { return &struct{ Listener []listener }{} },
+		},
+		{
+			text: `ports = [80, 443]`,
+			v:    func() interface{} { return &struct{ Ports []int }{} },
+		},
+		{
+			text: `ports = 80`,
+			v:    func() interface{} { return &struct{ Ports []int }{} },
+		},
+	}
+	for _, tt := range tests {
+		file := newTestFile(t, tt.text)
+		got, want := tt.v(), tt.v()
+		err := file.Decode(got)
+		wantErr := hcl.DecodeObject(want, file.Contents)
+		if (err == nil) != (wantErr == nil) {
+			t.Errorf("%s: got err=%v, want err=%v", tt.text, err, wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: got=%+v, want=%+v", tt.text, got, want)
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	RegisterDecodeHook(reflect.TypeOf(logLevel(0)), func(value interface{}) (interface{}, error) {
+		return nil, errors.New("unknown log level")
+	})
+	defer RegisterDecodeHook(reflect.TypeOf(logLevel(0)), nil)
+
+	file := newTestFile(t, `
+timeout = "thirty seconds"
+address = "not-an-ip"
+port = "eighty"
+server {
+	level = "verbose"
+}
+`)
+	var config struct {
+		Timeout time.Duration
+		Address net.IP
+		Port    int
+		Server  struct {
+			Level logLevel
+		}
+	}
+	err := file.Decode(&config)
+	errs, ok := err.(KeyErrors)
+	if !ok {
+		t.Fatalf("got %T, want KeyErrors: %v", err, err)
+	}
+	var got []string
+	for _, err := range errs {
+		got = append(got, fmt.Sprintf("%s:%d:%d", err.Key, err.Line, err.Column))
+	}
+	want := []string{"timeout:2:11", "address:3:11", "port:4:8", "server.level:6:10"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+}
+
+type tlsConfig struct {
+	Enabled  bool
+	CertFile string
+}
+
+func (c *tlsConfig) Validate() error {
+	if c.Enabled && c.CertFile == "" {
+		return errors.New("cert_file is required when TLS is enabled")
+	}
+	return nil
+}
+
+func TestDecodeDefaults(t *testing.T) {
+	file := newTestFile(t, `
+database {
+	hostname = "db.example.com"
+}
+`)
+	var config struct {
+		Database struct {
+			Hostname string        `validate:"required"`
+			Port     int           `hclconfig:"default=5432"`
+			Timeout  time.Duration `hclconfig:"default=30s"`
+			Mode     string        `hclconfig:"default=read-write" validate:"oneof=read-only read-write"`
+		}
+		Server struct {
+			Enabled bool `hclconfig:"default=true"`
+		}
+	}
+	if err := file.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Database.Port, 5432; got != want {
+		t.Errorf("port: got=%v, want=%v", got, want)
+	}
+	if got, want := config.Database.Timeout, 30*time.Second; got != want {
+		t.Errorf("timeout: got=%v, want=%v", got, want)
+	}
+	if got, want := config.Database.Mode, "read-write"; got != want {
+		t.Errorf("mode: got=%v, want=%v", got, want)
+	}
+	if !config.Server.Enabled {
+		t.Errorf("server.enabled: got=false, want=true")
+	}
+}
+
+func TestDecodeValidate(t *testing.T) {
+	file := newTestFile(t, `
+database {
+	port = 70000
+	mode = "delete"
+	hosts = []
+}
+tls {
+	enabled = true
+}
+`)
+	var config struct {
+		Database struct {
+			Hostname string   `validate:"required"`
+			Port     int      `validate:"min=1,max=65535"`
+			Mode     string   `validate:"oneof=read-only read-write"`
+			Hosts    []string `validate:"min=1"`
+		}
+		TLS tlsConfig
+	}
+	err := file.Decode(&config)
+	errs, ok := err.(KeyErrors)
+	if !ok {
+		t.Fatalf("got %T, want KeyErrors: %v", err, err)
+	}
+	var got []string
+	for _, err := range errs {
+		got = append(got, fmt.Sprintf("%s:%d: %s", err.Key, err.Line, err.Message))
+	}
+	want := []string{
+		"database.hostname:2: required key is missing",
+		"database.port:3: must be at most 65535",
+		"database.mode:4: must be one of: read-only, read-write",
+		"database.hosts:5: length must be at least 1",
+		"tls:7: cert_file is required when TLS is enabled",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got=%q\nwant=%q", got, want)
+	}
+}
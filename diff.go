@@ -0,0 +1,134 @@
+package hclconfig
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// ChangeKind describes how a value differs between two files.
+type ChangeKind int
+
+// Kinds of change
+const (
+	Added    ChangeKind = iota + 1 // value is only in the new file
+	Removed                        // value is only in the old file
+	Modified                       // value is different in the new file
+)
+
+// String returns "added", "removed" or "modified".
+func (k ChangeKind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	case Modified:
+		return "modified"
+	}
+	return "unknown"
+}
+
+// Change describes a single difference between two files.
+type Change struct {
+	Path string     // path of the value, eg "database.hostname"
+	Kind ChangeKind // added, removed or modified
+	Old  string     // old value, or empty if added
+	New  string     // new value, or empty if removed
+}
+
+// String returns a description of the change suitable for logging.
+func (c *Change) String() string {
+	switch c.Kind {
+	case Added:
+		return fmt.Sprintf("%s: added %s", c.Path, c.New)
+	case Removed:
+		return fmt.Sprintf("%s: removed %s", c.Path, c.Old)
+	}
+	return fmt.Sprintf("%s: modified %s -> %s", c.Path, c.Old, c.New)
+}
+
+// Diff compares the decrypted contents of two versions of a configuration
+// file, and returns the values that have been added, removed or modified,
+// sorted by path. Values are compared leaf by leaf, using the same paths
+// as DecodePath and Origins.
+//
+// Old and New values are formatted as they would be written in HCL.
+// Values that were encrypted in either file are compared after decryption,
+// but are redacted in the result.
+//
+// Either file can be nil, in which case it is treated as empty.
+func Diff(old, new *File) []*Change {
+	oldLeaves, oldPaths := fileLeaves(old)
+	newLeaves, newPaths := fileLeaves(new)
+
+	var changes []*Change
+	for _, path := range oldPaths {
+		o := oldLeaves[path]
+		n, ok := newLeaves[path]
+		if !ok {
+			changes = append(changes, &Change{Path: path, Kind: Removed, Old: o.String()})
+			continue
+		}
+		if o.value != n.value {
+			changes = append(changes, &Change{Path: path, Kind: Modified, Old: o.String(), New: n.String()})
+		}
+	}
+	for _, path := range newPaths {
+		if _, ok := oldLeaves[path]; !ok {
+			changes = append(changes, &Change{Path: path, Kind: Added, New: newLeaves[path].String()})
+		}
+	}
+
+	sort.SliceStable(changes, func(i, j int) bool {
+		return changes[i].Path < changes[j].Path
+	})
+	return changes
+}
+
+// leaf is the value of a leaf in a file, for comparison.
+type leaf struct {
+	value  string
+	secret bool
+}
+
+// String returns the value of the leaf for display.
+func (l leaf) String() string {
+	if l.secret {
+		return redacted
+	}
+	return l.value
+}
+
+// fileLeaves returns the leaves of the file keyed by path, and the paths
+// in the order they appear in the file.
+func fileLeaves(f *File) (map[string]leaf, []string) {
+	leaves := make(map[string]leaf)
+	var paths []string
+	if f == nil || f.Contents == nil {
+		return leaves, paths
+	}
+	walkLeaves("", objectList(f.Contents.Node), func(path string, node ast.Node) {
+		var l leaf
+		switch n := node.(type) {
+		case *ast.ObjectType:
+			l.value = "{}"
+		case *ast.ListType:
+			l.value = "[]"
+		case *ast.LiteralType:
+			l.secret = f.decrypted[n]
+			if s, ok := n.Token.Value().(string); ok {
+				l.value = strconv.Quote(s)
+			} else {
+				l.value = n.Token.Text
+			}
+		}
+		if _, ok := leaves[path]; !ok {
+			paths = append(paths, path)
+		}
+		leaves[path] = l
+	})
+	return leaves, paths
+}
@@ -0,0 +1,63 @@
+package hclconfig
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+func TestDiff(t *testing.T) {
+	old := newTestFile(t, `
+database {
+	hostname = "db1.example.com"
+	port = 5432
+	password = "old"
+}
+features {
+	beta = false
+}
+servers = ["a", "b"]
+removed = 1
+`)
+	new := newTestFile(t, `
+database {
+	hostname = "db1.example.com"
+	port = 5433
+	password = "new"
+}
+features {
+	beta = true
+	gamma = "on"
+}
+servers = ["a"]
+`)
+	// treat the passwords as having been encrypted
+	for _, f := range []*File{old, new} {
+		f.decrypted = make(map[*ast.LiteralType]bool)
+		f.decrypted[literalAt(t, f, "database.password")] = true
+	}
+
+	var got []string
+	for _, change := range Diff(old, new) {
+		got = append(got, change.String())
+	}
+	want := []string{
+		`database.password: modified [redacted] -> [redacted]`,
+		`database.port: modified 5432 -> 5433`,
+		`features.beta: modified false -> true`,
+		`features.gamma: added "on"`,
+		`removed: removed 1`,
+		`servers[1]: removed "b"`,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got=%q\nwant=%q", got, want)
+	}
+
+	if changes := Diff(old, old); len(changes) != 0 {
+		t.Errorf("got=%v, want no changes", changes)
+	}
+	if got, want := len(Diff(nil, new)), 6; got != want {
+		t.Errorf("got=%d, want=%d", got, want)
+	}
+}
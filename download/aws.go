@@ -0,0 +1,162 @@
+package download
+
+import (
+	"context"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/hclconfig/amzn"
+)
+
+// ssmFetcher downloads SSM parameters. The location of a parameter is
+// "ssm://" followed by the parameter name, without the leading slash of
+// a hierarchical name, eg "ssm://myapp/production/config" for the
+// parameter "/myapp/production/config". A specific version is selected
+// with a "version" query parameter, eg "?version=3".
+//
+// The ETag of the file is the parameter version, and the body is the
+// parameter value. SecureString parameters are decrypted, so the file
+// is marked as sensitive.
+type ssmFetcher struct {
+	client *amzn.Client
+}
+
+func (f *ssmFetcher) configure(d *Downloader) Fetcher {
+	return &ssmFetcher{client: d.awsClient()}
+}
+
+func (f *ssmFetcher) get(ctx context.Context, location string, includeBody bool) (*File, error) {
+	name, err := ssmParameterName(location)
+	if err != nil {
+		return nil, err
+	}
+	// a HEAD does not need the decrypted value
+	value, version, modified, err := f.client.GetParameter(ctx, name, includeBody)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{
+		Location:     location,
+		ETag:         strconv.FormatInt(version, 10),
+		LastModified: modified,
+	}
+	if includeBody {
+		file.Body = []byte(value)
+		file.Sensitive = true
+	}
+	return file, nil
+}
+
+func (f *ssmFetcher) Get(ctx context.Context, location string) (*File, error) {
+	return f.get(ctx, location, true)
+}
+
+func (f *ssmFetcher) Head(ctx context.Context, location string) (*File, error) {
+	return f.get(ctx, location, false)
+}
+
+// GetIfChanged gets the parameter and compares its version, as SSM does
+// not support conditional requests.
+func (f *ssmFetcher) GetIfChanged(ctx context.Context, location string, etag string, lastModified time.Time) (*File, bool, error) {
+	file, err := f.get(ctx, location, true)
+	if err != nil || !file.ChangedSince(etag, lastModified) {
+		return nil, false, err
+	}
+	return file, true, nil
+}
+
+// ssmParameterName returns the name of the SSM parameter at location.
+func ssmParameterName(location string) (string, error) {
+	u, err := url.Parse(location)
+	if err != nil || (u.Host == "" && u.Path == "") {
+		return "", errors.New("invalid SSM parameter location").With(
+			"location", RedactLocation(location),
+		)
+	}
+	name := u.Host
+	if u.Path != "" {
+		name = "/" + u.Host + u.Path
+		if u.Host == "" {
+			name = u.Path
+		}
+	}
+	if version := u.Query().Get("version"); version != "" {
+		name += ":" + version
+	}
+	return name, nil
+}
+
+// secretsFetcher downloads Secrets Manager secrets. The location of a
+// secret is "secretsmanager://" followed by the secret name, eg
+// "secretsmanager://myapp/production/config". A specific version is
+// selected with a "versionId" or "versionStage" query parameter.
+//
+// The ETag of the file is the version ID, and the body is the secret
+// string, or the secret binary if there is no secret string. The file
+// is marked as sensitive.
+type secretsFetcher struct {
+	client *amzn.Client
+}
+
+func (f *secretsFetcher) configure(d *Downloader) Fetcher {
+	return &secretsFetcher{client: d.awsClient()}
+}
+
+func (f *secretsFetcher) Get(ctx context.Context, location string) (*File, error) {
+	secretID, versionID, versionStage, err := secretLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	value, versionID, created, err := f.client.GetSecret(ctx, secretID, versionID, versionStage)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		Location:     location,
+		Body:         value,
+		ETag:         versionID,
+		LastModified: created,
+		Sensitive:    true,
+	}, nil
+}
+
+func (f *secretsFetcher) Head(ctx context.Context, location string) (*File, error) {
+	secretID, versionID, versionStage, err := secretLocation(location)
+	if err != nil {
+		return nil, err
+	}
+	versionID, modified, err := f.client.DescribeSecret(ctx, secretID, versionID, versionStage)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		Location:     location,
+		ETag:         versionID,
+		LastModified: modified,
+	}, nil
+}
+
+// GetIfChanged gets the secret and compares its version ID, as Secrets
+// Manager does not support conditional requests.
+func (f *secretsFetcher) GetIfChanged(ctx context.Context, location string, etag string, lastModified time.Time) (*File, bool, error) {
+	file, err := f.Get(ctx, location)
+	if err != nil || !file.ChangedSince(etag, lastModified) {
+		return nil, false, err
+	}
+	return file, true, nil
+}
+
+// secretLocation returns the secret ID, version ID and version stage of
+// the secret at location.
+func secretLocation(location string) (secretID, versionID, versionStage string, err error) {
+	u, err := url.Parse(location)
+	if err != nil || u.Host+u.Path == "" {
+		return "", "", "", errors.New("invalid Secrets Manager location").With(
+			"location", RedactLocation(location),
+		)
+	}
+	query := u.Query()
+	return u.Host + u.Path, query.Get("versionId"), query.Get("versionStage"), nil
+}
@@ -0,0 +1,207 @@
+package download
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/jjeffery/hclconfig/amzn"
+)
+
+// awsStub is a local stub of the SSM and Secrets Manager APIs.
+type awsStub struct {
+	mu      sync.Mutex
+	version int
+	inputs  []map[string]interface{}
+}
+
+func (s *awsStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var input map[string]interface{}
+	json.NewDecoder(r.Body).Decode(&input)
+	target := r.Header.Get("X-Amz-Target")
+	s.inputs = append(s.inputs, input)
+
+	var output interface{}
+	switch target {
+	case "AmazonSSM.GetParameter":
+		if input["Name"] != "/myapp/config" {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"__type":"ParameterNotFound","message":"not found"}`))
+			return
+		}
+		value := "encrypted"
+		if input["WithDecryption"] == true {
+			value = `version = ` + strconv.Itoa(s.version)
+		}
+		output = map[string]interface{}{
+			"Parameter": map[string]interface{}{
+				"Name":             "/myapp/config",
+				"Type":             "SecureString",
+				"Value":            value,
+				"Version":          s.version,
+				"LastModifiedDate": 1500000000 + s.version,
+			},
+		}
+	case "secretsmanager.GetSecretValue":
+		output = map[string]interface{}{
+			"Name":         input["SecretId"],
+			"SecretString": `version = ` + strconv.Itoa(s.version),
+			"VersionId":    "v" + strconv.Itoa(s.version),
+			"CreatedDate":  1500000000 + s.version,
+		}
+	case "secretsmanager.DescribeSecret":
+		output = map[string]interface{}{
+			"Name":            input["SecretId"],
+			"LastChangedDate": 1500000000 + s.version,
+			"VersionIdsToStages": map[string][]string{
+				"v0":                          {"AWSPREVIOUS"},
+				"v" + strconv.Itoa(s.version): {"AWSCURRENT"},
+			},
+		}
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+	json.NewEncoder(w).Encode(output)
+}
+
+func (s *awsStub) setVersion(version int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.version = version
+}
+
+// newAWSStub returns a stub and a downloader that sends AWS
+// requests to it.
+func newAWSStub(t *testing.T) (*awsStub, *Downloader) {
+	stub := &awsStub{version: 1}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stub, &Downloader{AWS: &amzn.Client{Session: sess}, Retry: NoRetry}
+}
+
+func TestGetSSM(t *testing.T) {
+	stub, d := newAWSStub(t)
+	ctx := context.Background()
+	location := "ssm://myapp/config"
+
+	file, err := d.Get(ctx, location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(file.Body), "version = 1"; got != want {
+		t.Errorf("body: got=%q, want=%q", got, want)
+	}
+	if got, want := file.ETag, "1"; got != want {
+		t.Errorf("etag: got=%q, want=%q", got, want)
+	}
+	if !file.Sensitive {
+		t.Error("got Sensitive=false, want true")
+	}
+
+	head, err := d.Head(ctx, location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.Body != nil || head.ChangedSince(file.ETag, file.LastModified) {
+		t.Errorf("head: got body=%q etag=%q, want unchanged", head.Body, head.ETag)
+	}
+	if got := stub.inputs[len(stub.inputs)-1]["WithDecryption"]; got != false {
+		t.Errorf("head: got WithDecryption=%v, want false", got)
+	}
+
+	stub.setVersion(2)
+	file, changed, err := d.GetIfChanged(ctx, location, file.ETag, file.LastModified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || string(file.Body) != "version = 2" {
+		t.Errorf("got changed=%v file=%v, want version 2", changed, file)
+	}
+
+	if _, err := d.Get(ctx, "ssm://missing"); err == nil || !strings.Contains(err.Error(), "ParameterNotFound") {
+		t.Errorf("got %v, want ParameterNotFound", err)
+	}
+}
+
+func TestGetSecretsManager(t *testing.T) {
+	stub, d := newAWSStub(t)
+	ctx := context.Background()
+	location := "secretsmanager://myapp/config"
+
+	file, err := d.Get(ctx, location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(file.Body), "version = 1"; got != want {
+		t.Errorf("body: got=%q, want=%q", got, want)
+	}
+	if got, want := file.ETag, "v1"; got != want {
+		t.Errorf("etag: got=%q, want=%q", got, want)
+	}
+	if !file.Sensitive {
+		t.Error("got Sensitive=false, want true")
+	}
+	if got, want := stub.inputs[0]["SecretId"], "myapp/config"; got != want {
+		t.Errorf("secret id: got=%v, want=%v", got, want)
+	}
+
+	head, err := d.Head(ctx, location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if head.ChangedSince(file.ETag, file.LastModified) {
+		t.Errorf("head: got etag=%q, want unchanged", head.ETag)
+	}
+
+	stub.setVersion(2)
+	head, err = d.Head(ctx, location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !head.ChangedSince(file.ETag, file.LastModified) {
+		t.Errorf("head: got etag=%q, want changed", head.ETag)
+	}
+}
+
+func TestSSMParameterName(t *testing.T) {
+	tests := []struct {
+		location string
+		want     string
+	}{
+		{location: "ssm://config", want: "config"},
+		{location: "ssm://myapp/config", want: "/myapp/config"},
+		{location: "ssm:///myapp/config", want: "/myapp/config"},
+		{location: "ssm://myapp/config?version=3", want: "/myapp/config:3"},
+	}
+	for _, tt := range tests {
+		got, err := ssmParameterName(tt.location)
+		if err != nil {
+			t.Errorf("%s: %v", tt.location, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("%s: got=%q, want=%q", tt.location, got, tt.want)
+		}
+	}
+}
@@ -1,12 +1,21 @@
 // Package download knows how to download a file from
-// an HTTP URL, an S3 URL or from the local filesystem.
+// an HTTP URL, an S3 URL, an SSM parameter, a Secrets Manager
+// secret or from the local filesystem.
+// Other URL schemes can be added using RegisterScheme.
+//
+// S3 URLs can have region, endpoint, versionId, profile and pathStyle
+// query parameters, so that files can be downloaded from a bucket in
+// another region or account, from a specific object version, or from
+// an S3-compatible server such as MinIO:
+//  s3://config/app.hcl?endpoint=http://localhost:9000&region=us-east-1
+// Path-style addressing is used by default when an endpoint is specified.
 package download
 
 import (
+	"context"
 	"io"
 	"io/ioutil"
 	"net/http"
-	"net/url"
 	"os"
 	"strings"
 	"time"
@@ -16,56 +25,269 @@ import (
 )
 
 var (
-	httpClient = http.Client{
+	// defaultHTTPClient is used when the Downloader does not
+	// specify an HTTP client.
+	defaultHTTPClient = &http.Client{
 		Timeout: time.Minute,
 	}
+
+	// defaultDownloader is used by the package-level functions.
+	defaultDownloader = &Downloader{}
 )
 
+// Downloader downloads files using the configured HTTP client and
+// AWS client. The zero value is ready to use.
+type Downloader struct {
+	// HTTPClient is used for HTTP and HTTPS locations. If nil,
+	// a client with a one minute timeout is used.
+	HTTPClient *http.Client
+
+	// HTTPOptions configures requests to HTTP and HTTPS locations that
+	// do not match any of the prefixes in HTTPLocations. If nil, requests
+	// have no additional headers or credentials.
+	HTTPOptions *HTTPOptions
+
+	// HTTPLocations configures requests to HTTP and HTTPS locations that
+	// start with a prefix, eg "https://config.example.com/". If more than
+	// one prefix matches a location, the longest prefix is used. Prefixes
+	// are matched against the location without any URL credentials.
+	HTTPLocations map[string]*HTTPOptions
+
+	// AWS is used for S3, SSM and Secrets Manager locations. If nil,
+	// the default AWS session is used.
+	AWS *amzn.Client
+
+	// Schemes lists the URL schemes that the downloader is permitted
+	// to access, eg "https", "s3" or "file". Local file paths are treated
+	// as having the "file" scheme. If empty, all registered schemes are
+	// permitted. See RegisterScheme.
+	Schemes []string
+
+	// Retry determines how requests that fail with a transient error
+	// are retried. If nil, DefaultRetryPolicy is used.
+	Retry *RetryPolicy
+
+	// Observer, if not nil, is notified of every request.
+	Observer Observer
+}
+
 // File represents a file that has been downloaded
-// from HTTP, S3 or the local filesystem.
+// from HTTP, S3, the local filesystem or a registered scheme.
 type File struct {
 	Location     string
 	Body         []byte
 	ETag         string
 	LastModified time.Time
 	IsLocal      bool
+
+	// Sensitive is true if the body is a secret that has been decrypted
+	// by the server, such as an SSM SecureString parameter or a Secrets
+	// Manager secret. Sensitive bodies should not be stored on disk.
+	Sensitive bool
+}
+
+// ChangedSince reports whether f is a different version of the file to
+// the version with the specified ETag and last modified time. If both
+// versions have an ETag they are compared, otherwise f has changed if it
+// was modified after lastModified.
+func (f *File) ChangedSince(etag string, lastModified time.Time) bool {
+	if f.ETag != "" && etag != "" {
+		return f.ETag != etag
+	}
+	return f.LastModified.After(lastModified)
+}
+
+// condition identifies the version of a file that the caller already has,
+// for conditional requests.
+type condition struct {
+	etag         string
+	lastModified time.Time
 }
 
 // Head returns a file without the body. It can be used to determine
 // if the file has changed.
 func Head(location string) (*File, error) {
-	return get(location, false)
+	return defaultDownloader.Head(context.Background(), location)
+}
+
+// HeadContext returns a file without the body. It can be used to determine
+// if the file has changed. Cancelling the context aborts the request.
+func HeadContext(ctx context.Context, location string) (*File, error) {
+	return defaultDownloader.Head(ctx, location)
 }
 
 // Get returns a file from the specified location, including the body.
 func Get(location string) (*File, error) {
-	return get(location, true)
+	return defaultDownloader.Get(context.Background(), location)
+}
+
+// GetContext returns a file from the specified location, including the body.
+// Cancelling the context aborts the request.
+func GetContext(ctx context.Context, location string) (*File, error) {
+	return defaultDownloader.Get(ctx, location)
+}
+
+// GetIfChanged returns the file at the specified location, including the
+// body, if it has changed since the version with the specified ETag and
+// last modified time. If the file has not changed, it returns a nil file
+// and changed is false. See Downloader.GetIfChanged for details.
+func GetIfChanged(ctx context.Context, location string, etag string, lastModified time.Time) (file *File, changed bool, err error) {
+	return defaultDownloader.GetIfChanged(ctx, location, etag, lastModified)
+}
+
+// Head returns a file without the body. It can be used to determine
+// if the file has changed. Cancelling the context aborts the request.
+func (d *Downloader) Head(ctx context.Context, location string) (*File, error) {
+	return d.get(ctx, location, false, nil)
+}
+
+// Get returns a file from the specified location, including the body.
+// Cancelling the context aborts the request.
+func (d *Downloader) Get(ctx context.Context, location string) (*File, error) {
+	return d.get(ctx, location, true, nil)
 }
 
-func get(location string, includeBody bool) (*File, error) {
-	u, err := url.Parse(location)
+// GetIfChanged returns the file at the specified location, including the
+// body, if it has changed since the version with the specified ETag and
+// last modified time. If the file has not changed, it returns a nil file
+// and changed is false.
+//
+// This takes a single round trip. For HTTP(S) URLs it sends a conditional
+// GET request with If-None-Match and If-Modified-Since headers. For S3 URLs
+// it sends a GET request with IfNoneMatch. For local files it performs
+// a file stat, and only reads the file if it has been modified.
+func (d *Downloader) GetIfChanged(ctx context.Context, location string, etag string, lastModified time.Time) (file *File, changed bool, err error) {
+	file, err = d.get(ctx, location, true, &condition{
+		etag:         etag,
+		lastModified: lastModified,
+	})
 	if err != nil {
-		// not a valid URL, so treat as a local file
-		return getLocal(location, includeBody)
-	}
-
-	switch strings.ToLower(u.Scheme) {
-	case "http", "https":
-		return getHTTP(location, includeBody)
-	case "s3":
-		bucket := u.Host
-		key := strings.TrimPrefix(u.Path, "/")
-		return getS3(location, bucket, key, includeBody)
-	case "file", "":
-		return getLocal(u.Path, includeBody)
+		return nil, false, err
+	}
+	return file, file != nil, nil
+}
+
+// get returns the file at location. If cond is not nil and the file
+// has not changed, get returns a nil file and a nil error.
+func (d *Downloader) get(ctx context.Context, location string, includeBody bool, cond *condition) (*File, error) {
+	if d.Observer == nil {
+		file, _, err := d.fetch(ctx, location, includeBody, cond)
+		return file, err
+	}
+
+	method := "GET"
+	if !includeBody {
+		method = "HEAD"
+	}
+	scheme := schemeOf(location)
+	d.Observer.FetchStart(FetchStartEvent{
+		Location: RedactLocation(location),
+		Scheme:   scheme,
+		Method:   method,
+	})
+	start := time.Now()
+	file, attempts, err := d.fetch(ctx, location, includeBody, cond)
+	e := FetchEvent{
+		Location: RedactLocation(location),
+		Scheme:   scheme,
+		Method:   method,
+		Status:   StatusOK,
+		Attempts: attempts,
+		Duration: time.Since(start),
+		Err:      err,
+	}
+	switch {
+	case err != nil:
+		e.Status = StatusError
+	case file == nil:
+		e.Status = StatusNotModified
 	default:
+		e.Bytes = len(file.Body)
+	}
+	d.Observer.FetchDone(e)
+	return file, err
+}
+
+// fetch performs the request for get, retrying transient errors
+// according to the retry policy. It returns the number of attempts.
+func (d *Downloader) fetch(ctx context.Context, location string, includeBody bool, cond *condition) (*File, int, error) {
+	policy := d.retryPolicy()
+	for attempts := 1; ; attempts++ {
+		file, err := d.fetchOnce(ctx, location, includeBody, cond)
+		if err == nil {
+			return file, attempts, nil
+		}
+		retry, retryAfter := isRetryable(ctx, err)
+		if !retry {
+			return nil, attempts, withAttempts(err, attempts)
+		}
+		delay, ok := policy.delay(attempts, retryAfter)
+		if !ok || !sleep(ctx, delay) {
+			return nil, attempts, withAttempts(err, attempts)
+		}
+	}
+}
+
+// withAttempts adds the number of attempts to err if the request
+// was retried.
+func withAttempts(err error, attempts int) error {
+	if attempts <= 1 {
+		return err
+	}
+	return errors.Wrap(err).With("attempts", attempts)
+}
+
+// fetchOnce sends a single request for fetch, using the fetcher
+// registered for the scheme of the location.
+func (d *Downloader) fetchOnce(ctx context.Context, location string, includeBody bool, cond *condition) (*File, error) {
+	scheme := schemeOf(location)
+	if !d.allowScheme(scheme) {
+		return nil, errSchemeNotPermitted(location)
+	}
+	fetcher := d.fetcher(scheme)
+	if fetcher == nil {
 		return nil, errors.New("cannot open file: unknown scheme").With(
-			"location", location,
+			"location", RedactLocation(location),
 		)
 	}
+
+	switch {
+	case !includeBody:
+		return fetcher.Head(ctx, location)
+	case cond != nil:
+		file, _, err := fetcher.GetIfChanged(ctx, location, cond.etag, cond.lastModified)
+		return file, err
+	default:
+		return fetcher.Get(ctx, location)
+	}
+}
+
+func (d *Downloader) httpClient() *http.Client {
+	if d.HTTPClient == nil {
+		return defaultHTTPClient
+	}
+	return d.HTTPClient
+}
+
+func (d *Downloader) allowScheme(scheme string) bool {
+	if len(d.Schemes) == 0 {
+		return true
+	}
+	for _, s := range d.Schemes {
+		if strings.EqualFold(s, scheme) {
+			return true
+		}
+	}
+	return false
 }
 
-func getLocal(location string, includeBody bool) (*File, error) {
+func errSchemeNotPermitted(location string) error {
+	return errors.New("cannot open file: scheme not permitted").With(
+		"location", RedactLocation(location),
+	)
+}
+
+func getLocal(location string, includeBody bool, cond *condition) (*File, error) {
 	f, err := os.Open(location)
 	if err != nil {
 		// error message contains file name
@@ -79,6 +301,11 @@ func getLocal(location string, includeBody bool) (*File, error) {
 		return nil, err
 	}
 
+	if cond != nil && !fi.ModTime().After(cond.lastModified) {
+		// not modified
+		return nil, nil
+	}
+
 	var body []byte
 
 	if includeBody {
@@ -98,33 +325,56 @@ func getLocal(location string, includeBody bool) (*File, error) {
 	}, nil
 }
 
-func getHTTP(location string, includeBody bool) (*File, error) {
+func getHTTP(ctx context.Context, client *http.Client, opts *HTTPOptions, location string, includeBody bool, cond *condition) (*File, error) {
 	method := "GET"
 	if !includeBody {
-		method = "HEAD:"
+		method = "HEAD"
 	}
+	// location can contain credentials, so is never used in errors
+	redacted := RedactLocation(location)
 	request, err := http.NewRequest(method, location, nil)
 	if err != nil {
+		return nil, errors.New("cannot create http request").With(
+			"location", redacted,
+		)
+	}
+	if err := opts.authorize(request); err != nil {
 		return nil, errors.Wrap(err, "cannot create http request").With(
-			"location", location,
+			"location", redacted,
+		)
+	}
+	if cond != nil {
+		if cond.etag != "" {
+			request.Header.Set("If-None-Match", cond.etag)
+		}
+		if !cond.lastModified.IsZero() {
+			request.Header.Set("If-Modified-Since", cond.lastModified.UTC().Format(http.TimeFormat))
+		}
+	}
+	client, err = opts.client(client)
+	if err != nil {
+		return nil, errors.Wrap(err).With(
+			"location", redacted,
 		)
 	}
 
-	response, err := httpClient.Do(request)
+	response, err := client.Do(request.WithContext(ctx))
 	if err != nil {
 		return nil, errors.Wrap(err, "cannot get file").With(
-			"location", location,
+			"location", redacted,
 			"method", method,
 		)
 	}
 	defer response.Body.Close()
 
+	if cond != nil && response.StatusCode == http.StatusNotModified {
+		return nil, nil
+	}
 	if response.StatusCode != http.StatusOK {
-		return nil, errors.New("cannot get file").With(
-			"location", location,
+		return nil, errors.Wrap(newStatusError(response), "cannot get file").With(
+			"location", redacted,
 			"method", method,
 			"statusCode", response.StatusCode,
-			"status", response.Status,
 		)
 	}
 
@@ -134,7 +384,7 @@ func getHTTP(location string, includeBody bool) (*File, error) {
 		body, err = ioutil.ReadAll(response.Body)
 		if err != nil {
 			return nil, errors.Wrap(err, "cannot read file body").With(
-				"location", location,
+				"location", redacted,
 			)
 		}
 	}
@@ -148,10 +398,15 @@ func getHTTP(location string, includeBody bool) (*File, error) {
 		LastModified: lastModified,
 	}
 
+	if cond != nil && !file.ChangedSince(cond.etag, cond.lastModified) {
+		// the server does not support conditional requests
+		return nil, nil
+	}
+
 	return file, nil
 }
 
-func getS3(location, bucket, key string, includeBody bool) (*File, error) {
+func getS3(ctx context.Context, client *amzn.Client, location string, obj *amzn.S3Object, includeBody bool, cond *condition) (*File, error) {
 	var etag string
 	var lastModified time.Time
 	var body io.ReadCloser
@@ -159,20 +414,31 @@ func getS3(location, bucket, key string, includeBody bool) (*File, error) {
 	var err error
 
 	if includeBody {
-		etag, lastModified, body, err = amzn.Get(bucket, key)
-		if err != nil {
-			return nil, err
+		if cond != nil && cond.etag != "" {
+			var changed bool
+			etag, lastModified, body, changed, err = client.GetObjectIfChanged(ctx, obj, cond.etag)
+			if err != nil {
+				return nil, err
+			}
+			if !changed {
+				return nil, nil
+			}
+		} else {
+			etag, lastModified, body, err = client.GetObject(ctx, obj)
+			if err != nil {
+				return nil, err
+			}
 		}
 		defer body.Close()
 		bodyBytes, err = ioutil.ReadAll(body)
 		if err != nil {
 			return nil, errors.Wrap(err, "cannot download from s3").With(
-				"bucket", bucket,
-				"key", key,
+				"bucket", obj.Bucket,
+				"key", obj.Key,
 			)
 		}
 	} else {
-		etag, lastModified, err = amzn.Head(bucket, key)
+		etag, lastModified, err = client.HeadObject(ctx, obj)
 		if err != nil {
 			return nil, err
 		}
@@ -186,7 +452,3 @@ func getS3(location, bucket, key string, includeBody bool) (*File, error) {
 	}
 	return file, nil
 }
-
-func getS3Changed(bucket string, key string, etag string) (changed bool, err error) {
-	return amzn.HasChanged(bucket, key, etag)
-}
@@ -0,0 +1,139 @@
+package download
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestGetHTTP(t *testing.T) {
+	lastModified := time.Date(2017, 9, 1, 10, 0, 0, 0, time.UTC)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Etag", `"abc"`)
+		w.Header().Set("Last-Modified", lastModified.Format(http.TimeFormat))
+		w.Write([]byte(`value = "one"`))
+	}))
+	defer server.Close()
+
+	for _, includeBody := range []bool{true, false} {
+		var file *File
+		var err error
+		if includeBody {
+			file, err = Get(server.URL)
+		} else {
+			file, err = Head(server.URL)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := file.ETag, `"abc"`; got != want {
+			t.Errorf("got=%q, want=%q", got, want)
+		}
+		if got, want := file.LastModified, lastModified; !got.Equal(want) {
+			t.Errorf("got=%v, want=%v", got, want)
+		}
+		wantBody := ""
+		if includeBody {
+			wantBody = `value = "one"`
+		}
+		if got, want := string(file.Body), wantBody; got != want {
+			t.Errorf("got=%q, want=%q", got, want)
+		}
+	}
+}
+
+func TestGetContextCancel(t *testing.T) {
+	unblock := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-unblock
+	}))
+	defer server.Close()
+	defer close(unblock)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	if _, err := GetContext(ctx, server.URL); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("request was not cancelled: elapsed=%v", elapsed)
+	}
+}
+
+func TestGetIfChangedHTTP(t *testing.T) {
+	etag := `"1"`
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Etag", etag)
+		w.Write([]byte(`value = "one"`))
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	file, changed, err := GetIfChanged(ctx, server.URL, `"0"`, time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || file == nil {
+		t.Fatalf("got changed=%v file=%v, want changed", changed, file)
+	}
+	if got, want := string(file.Body), `value = "one"`; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+
+	file, changed, err = GetIfChanged(ctx, server.URL, file.ETag, file.LastModified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || file != nil {
+		t.Errorf("got changed=%v file=%v, want unchanged", changed, file)
+	}
+	if got, want := requests, 2; got != want {
+		t.Errorf("requests: got=%d, want=%d", got, want)
+	}
+}
+
+func TestGetIfChangedLocal(t *testing.T) {
+	f, err := ioutil.TempFile("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`value = "one"`)
+	f.Close()
+
+	ctx := context.Background()
+	fi, err := os.Stat(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, changed, err := GetIfChanged(ctx, f.Name(), "", fi.ModTime())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || file != nil {
+		t.Errorf("got changed=%v file=%v, want unchanged", changed, file)
+	}
+
+	file, changed, err = GetIfChanged(ctx, f.Name(), "", fi.ModTime().Add(-time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed || file == nil {
+		t.Fatalf("got changed=%v file=%v, want changed", changed, file)
+	}
+	if got, want := string(file.Body), `value = "one"`; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+}
@@ -0,0 +1,54 @@
+package download
+
+import (
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Fetch statuses reported to an Observer.
+const (
+	StatusOK          = "ok"           // file was downloaded
+	StatusNotModified = "not-modified" // conditional request found no change
+	StatusError       = "error"        // download failed
+)
+
+// Observer is notified of every download performed by a Downloader.
+// It can be used to collect metrics or to log requests. Implementations
+// must be safe for concurrent use.
+type Observer interface {
+	// FetchStart is called before a file is requested.
+	FetchStart(e FetchStartEvent)
+
+	// FetchDone is called when a request has finished.
+	FetchDone(e FetchEvent)
+}
+
+// FetchStartEvent describes a request that is about to be sent.
+type FetchStartEvent struct {
+	Location string // location of the file, with any password redacted
+	Scheme   string // eg "https", "s3" or "file"
+	Method   string // "GET" or "HEAD"
+}
+
+// FetchEvent describes a request that has finished.
+type FetchEvent struct {
+	Location string        // location of the file, with any password redacted
+	Scheme   string        // eg "https", "s3" or "file"
+	Method   string        // "GET" or "HEAD"
+	Status   string        // StatusOK, StatusNotModified or StatusError
+	Bytes    int           // size of the body downloaded
+	Attempts int           // number of requests sent, including retries
+	Duration time.Duration // time taken by the request, including retries
+	Err      error         // error if Status is StatusError
+}
+
+// schemeOf returns the scheme of the location, using "file" for
+// local file paths.
+func schemeOf(location string) string {
+	u, err := url.Parse(location)
+	if err != nil || u.Scheme == "" {
+		return "file"
+	}
+	return strings.ToLower(u.Scheme)
+}
@@ -0,0 +1,220 @@
+package download
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/hclconfig/amzn"
+)
+
+// ErrConflict is the cause of the error returned by Put when the file
+// has been modified since the expected version was downloaded. Use
+// errors.Cause to check for it:
+//  if errors.Cause(err) == download.ErrConflict {
+//      // download the file again and reapply the changes
+//  }
+var ErrConflict = amzn.ErrConflict
+
+// Putter is implemented by a Fetcher that can also write files. The
+// fetchers for HTTP, HTTPS, S3 and local files implement Putter.
+type Putter interface {
+	// Put writes the file at location. If version is not nil, the file
+	// is only written if it has not been modified since version was
+	// downloaded, and the cause of the error is ErrConflict if it has.
+	// Put returns the new version of the file, without the body.
+	Put(ctx context.Context, location string, body []byte, version *File) (*File, error)
+}
+
+// Put writes the contents of the file at location. If version is not
+// nil, the file is only written if it has not been modified since
+// version was downloaded. See Downloader.Put for details.
+func Put(location string, body []byte, version *File) (*File, error) {
+	return defaultDownloader.Put(context.Background(), location, body, version)
+}
+
+// PutContext is like Put, but cancelling the context aborts the request.
+func PutContext(ctx context.Context, location string, body []byte, version *File) (*File, error) {
+	return defaultDownloader.Put(ctx, location, body, version)
+}
+
+// Put writes the contents of the file at location, and returns the new
+// version of the file without the body. Cancelling the context aborts
+// the request.
+//
+// If version is not nil, the write is conditional on the file not having
+// been modified since version was downloaded:
+//  - for HTTP(S) URLs, the PUT request has an If-Match header with the
+//    ETag of version or, if it has no ETag, an If-Unmodified-Since
+//    header with its last modified time
+//  - for S3 URLs, the PutObject request has an If-Match header with
+//    the ETag of version
+//  - for local files, the modification time of the file must equal the
+//    last modified time of version
+// If the file has been modified, the cause of the returned error is
+// ErrConflict. If version does not have the ETag or last modified time
+// needed for the condition, Put returns an error rather than writing
+// the file unconditionally.
+//
+// Local files are replaced atomically. If a local file is a symbolic
+// link, the file it refers to is replaced, and the link is left in place.
+//
+// Writes are not retried, as a write that succeeded but returned an error
+// would then appear to be a conflict.
+func (d *Downloader) Put(ctx context.Context, location string, body []byte, version *File) (*File, error) {
+	scheme := schemeOf(location)
+	if !d.allowScheme(scheme) {
+		return nil, errSchemeNotPermitted(location)
+	}
+	putter, ok := d.fetcher(scheme).(Putter)
+	if !ok {
+		return nil, errors.New("cannot write file: scheme does not support writing").With(
+			"location", RedactLocation(location),
+		)
+	}
+	return putter.Put(ctx, location, body, version)
+}
+
+// errUnknownVersion is returned by Put when the version of the file
+// does not have the ETag or last modified time needed for a condition.
+func errUnknownVersion(location string) error {
+	return errors.New("cannot write file: version has no ETag or last modified time").With(
+		"location", RedactLocation(location),
+	)
+}
+
+func (f *httpFetcher) Put(ctx context.Context, location string, body []byte, version *File) (*File, error) {
+	opts := httpOptions(f.options, f.locations, location)
+	redacted := RedactLocation(location)
+	request, err := http.NewRequest("PUT", location, bytes.NewReader(body))
+	if err != nil {
+		return nil, errors.New("cannot create http request").With(
+			"location", redacted,
+		)
+	}
+	if err := opts.authorize(request); err != nil {
+		return nil, errors.Wrap(err, "cannot create http request").With(
+			"location", redacted,
+		)
+	}
+	if version != nil {
+		switch {
+		case version.ETag != "":
+			request.Header.Set("If-Match", version.ETag)
+		case !version.LastModified.IsZero():
+			request.Header.Set("If-Unmodified-Since", version.LastModified.UTC().Format(http.TimeFormat))
+		default:
+			return nil, errUnknownVersion(location)
+		}
+	}
+	client, err := opts.client(f.httpClient())
+	if err != nil {
+		return nil, errors.Wrap(err).With(
+			"location", redacted,
+		)
+	}
+
+	response, err := client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot put file").With(
+			"location", redacted,
+		)
+	}
+	defer response.Body.Close()
+
+	switch response.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+	case http.StatusPreconditionFailed, http.StatusConflict:
+		return nil, errors.Wrap(ErrConflict, "cannot put file").With(
+			"location", redacted,
+			"statusCode", response.StatusCode,
+		)
+	default:
+		return nil, errors.Wrap(newStatusError(response), "cannot put file").With(
+			"location", redacted,
+			"statusCode", response.StatusCode,
+		)
+	}
+
+	lastModified, _ := http.ParseTime(response.Header.Get("Last-Modified"))
+	return &File{
+		Location:     location,
+		ETag:         response.Header.Get("Etag"),
+		LastModified: lastModified,
+	}, nil
+}
+
+func (f *s3Fetcher) Put(ctx context.Context, location string, body []byte, version *File) (*File, error) {
+	obj, err := parseS3Location(location)
+	if err != nil {
+		return nil, err
+	}
+	var ifMatch string
+	if version != nil {
+		if version.ETag == "" {
+			return nil, errUnknownVersion(location)
+		}
+		ifMatch = version.ETag
+	}
+	etag, err := f.client.PutObject(ctx, obj, body, ifMatch)
+	if err != nil {
+		return nil, err
+	}
+	return &File{
+		Location: location,
+		ETag:     etag,
+	}, nil
+}
+
+func (localFetcher) Put(ctx context.Context, location string, body []byte, version *File) (*File, error) {
+	filename := localPath(location)
+	mode := os.FileMode(0644)
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		// replace the file that the link refers to, not the link
+		filename = target
+	}
+	fi, err := os.Stat(filename)
+	if err == nil {
+		mode = fi.Mode().Perm()
+	}
+	if version != nil {
+		if version.LastModified.IsZero() {
+			return nil, errUnknownVersion(location)
+		}
+		if err != nil || !fi.ModTime().Equal(version.LastModified) {
+			return nil, errors.Wrap(ErrConflict, "cannot write file").With(
+				"location", location,
+			)
+		}
+	}
+
+	// write to a temporary file and rename, so that the file is
+	// never partially written
+	tmp, err := ioutil.TempFile(filepath.Dir(filename), filepath.Base(filename)+".tmp")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return nil, errors.Wrap(err, "cannot write file").With(
+			"location", location,
+		)
+	}
+	if err := tmp.Close(); err != nil {
+		return nil, errors.Wrap(err, "cannot write file").With(
+			"location", location,
+		)
+	}
+	if err := os.Chmod(tmp.Name(), mode); err != nil {
+		return nil, err
+	}
+	if err := os.Rename(tmp.Name(), filename); err != nil {
+		return nil, err
+	}
+	return getLocal(localPath(location), false, nil)
+}
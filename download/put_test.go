@@ -0,0 +1,224 @@
+package download
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/hclconfig/amzn"
+)
+
+// putStub is a server that stores a single file, and supports
+// conditional PUT requests. The ETag is the number of writes.
+type putStub struct {
+	mu      sync.Mutex
+	version int
+	body    string
+}
+
+func (s *putStub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	etag := fmt.Sprintf(`"%d"`, s.version)
+	switch r.Method {
+	case "GET":
+		w.Header().Set("ETag", etag)
+		w.Write([]byte(s.body))
+	case "PUT":
+		if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != etag {
+			w.WriteHeader(http.StatusPreconditionFailed)
+			w.Write([]byte(`<Error><Code>PreconditionFailed</Code><Message>precondition failed</Message></Error>`))
+			return
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		s.body = string(body)
+		s.version++
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, s.version))
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func TestPutHTTP(t *testing.T) {
+	stub := &putStub{body: `value = "one"`}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+	testPut(t, &Downloader{}, server.URL+"/app.hcl", stub)
+}
+
+func TestPutS3(t *testing.T) {
+	stub := &putStub{body: `value = "one"`}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Downloader{AWS: &amzn.Client{Session: sess}}
+	testPut(t, d, "s3://config/app.hcl?endpoint="+server.URL, stub)
+}
+
+func testPut(t *testing.T, d *Downloader, location string, stub *putStub) {
+	t.Helper()
+	ctx := context.Background()
+	file, err := d.Get(ctx, location)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newFile, err := d.Put(ctx, location, []byte(`value = "two"`), file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := newFile.ETag, `"1"`; got != want {
+		t.Errorf("etag: got=%q, want=%q", got, want)
+	}
+	if got, want := stub.body, `value = "two"`; got != want {
+		t.Errorf("body: got=%q, want=%q", got, want)
+	}
+
+	// file is now out of date
+	_, err = d.Put(ctx, location, []byte(`value = "three"`), file)
+	if err == nil {
+		t.Fatal("got nil, want conflict")
+	}
+	if errors.Cause(err) != ErrConflict {
+		t.Errorf("got %v, want ErrConflict", err)
+	}
+	if got, want := stub.body, `value = "two"`; got != want {
+		t.Errorf("body: got=%q, want=%q", got, want)
+	}
+
+	// a version without an ETag cannot be checked
+	if _, err := d.Put(ctx, location, []byte(`value = "three"`), &File{}); err == nil {
+		t.Error("got nil, want error for version without ETag")
+	}
+	if got, want := stub.body, `value = "two"`; got != want {
+		t.Errorf("body: got=%q, want=%q", got, want)
+	}
+
+	// unconditional
+	if _, err := d.Put(ctx, location, []byte(`value = "four"`), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stub.body, `value = "four"`; got != want {
+		t.Errorf("body: got=%q, want=%q", got, want)
+	}
+}
+
+func TestPutLocal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filename := filepath.Join(dir, "app.hcl")
+	if err := ioutil.WriteFile(filename, []byte(`value = "one"`), 0640); err != nil {
+		t.Fatal(err)
+	}
+	oldTime := time.Now().Add(-time.Hour)
+	if err := os.Chtimes(filename, oldTime, oldTime); err != nil {
+		t.Fatal(err)
+	}
+	version, err := Get(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := Put(filename, []byte(`value = "two"`), version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !file.IsLocal || file.LastModified.IsZero() {
+		t.Errorf("got %+v, want local file", file)
+	}
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(data), `value = "two"`; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+	fi, err := os.Stat(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := fi.Mode().Perm(), os.FileMode(0640); got != want {
+		t.Errorf("mode: got=%v, want=%v", got, want)
+	}
+	entries, _ := ioutil.ReadDir(dir)
+	if len(entries) != 1 {
+		t.Errorf("got %d files, want 1", len(entries))
+	}
+
+	// version is now out of date
+	_, err = Put(filename, []byte(`value = "three"`), version)
+	if errors.Cause(err) != ErrConflict {
+		t.Errorf("got %v, want ErrConflict", err)
+	}
+
+	// unconditional
+	if _, err := Put(filename, []byte(`value = "four"`), nil); err != nil {
+		t.Fatal(err)
+	}
+	if data, _ := ioutil.ReadFile(filename); string(data) != `value = "four"` {
+		t.Errorf("got=%q, want=%q", data, `value = "four"`)
+	}
+}
+
+func TestPutLocalSymlink(t *testing.T) {
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// similar to a Kubernetes ConfigMap volume
+	target := filepath.Join(dir, "data", "app.hcl")
+	if err := os.Mkdir(filepath.Dir(target), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(target, []byte(`value = "one"`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	filename := filepath.Join(dir, "app.hcl")
+	if err := os.Symlink(target, filename); err != nil {
+		t.Skip(err)
+	}
+	version, err := Get(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Put(filename, []byte(`value = "two"`), version); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Lstat(filename); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Errorf("got %v, %v, want symbolic link", fi, err)
+	}
+	if data, _ := ioutil.ReadFile(target); string(data) != `value = "two"` {
+		t.Errorf("got=%q, want=%q", data, `value = "two"`)
+	}
+}
+
+func TestPutNotSupported(t *testing.T) {
+	if _, err := Put("ssm://myapp/config", []byte("x"), nil); err == nil {
+		t.Error("got nil, want error")
+	}
+}
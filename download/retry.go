@@ -0,0 +1,218 @@
+package download
+
+import (
+	"context"
+	stderrors "errors"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/hclconfig/amzn"
+)
+
+var (
+	// DefaultRetryPolicy is used by a Downloader that does not specify
+	// a retry policy.
+	DefaultRetryPolicy = &RetryPolicy{
+		MaxAttempts: 3,
+		BaseDelay:   200 * time.Millisecond,
+		MaxDelay:    10 * time.Second,
+		Jitter:      0.5,
+	}
+
+	// NoRetry is a retry policy that disables retries.
+	NoRetry = &RetryPolicy{MaxAttempts: 1}
+)
+
+// RetryPolicy determines how a Downloader retries requests that fail
+// with a transient error. The following errors are retried:
+//  - network errors, such as a refused or reset connection
+//  - HTTP responses with status 429 (Too Many Requests) or 5xx
+//  - AWS errors with a throttling code, such as SlowDown or
+//    ThrottlingException, or with status 5xx
+// Errors caused by cancelling the context are never retried.
+//
+// When a request is retried, the returned error has an "attempts"
+// value containing the number of requests sent. The AWS SDK does not
+// retry requests made by a Downloader, so the policy applies to AWS
+// requests too.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times a request is sent,
+	// including the first. A value of 1 or less disables retries.
+	MaxAttempts int
+
+	// BaseDelay is the delay before the first retry. The delay
+	// doubles for each subsequent retry.
+	BaseDelay time.Duration
+
+	// MaxDelay limits the delay between attempts, including a delay
+	// requested by the Retry-After header of a response. If the server
+	// requests a longer delay, the request is retried after MaxDelay.
+	// Zero means no limit.
+	MaxDelay time.Duration
+
+	// Jitter is the fraction of each delay that is random, between 0
+	// and 1. For example, a jitter of 0.5 with a delay of 1s results in
+	// a delay between 500ms and 1s. Jitter prevents many clients from
+	// retrying at the same time.
+	Jitter float64
+}
+
+// delay returns the delay before the next attempt, given the number of
+// attempts already made and the delay requested by the server, if any.
+// It returns false if the request should not be retried.
+func (p *RetryPolicy) delay(attempts int, retryAfter time.Duration) (time.Duration, bool) {
+	if attempts >= p.MaxAttempts {
+		return 0, false
+	}
+	if retryAfter > 0 {
+		if p.MaxDelay > 0 && retryAfter > p.MaxDelay {
+			retryAfter = p.MaxDelay
+		}
+		return retryAfter, true
+	}
+
+	d := p.BaseDelay
+	for i := 1; i < attempts && (p.MaxDelay <= 0 || d < p.MaxDelay); i++ {
+		d *= 2
+	}
+	if p.MaxDelay > 0 && d > p.MaxDelay {
+		d = p.MaxDelay
+	}
+	if jitter := p.Jitter; jitter > 0 && d > 0 {
+		if jitter > 1 {
+			jitter = 1
+		}
+		d -= time.Duration(rand.Float64() * jitter * float64(d))
+	}
+	return d, true
+}
+
+// throttleCodes contains AWS error codes that indicate a request was
+// throttled, in addition to those recognized by the AWS SDK.
+var throttleCodes = map[string]bool{
+	"SlowDown":                 true,
+	"RequestLimitExceeded":     true,
+	"RequestThrottled":         true,
+	"TooManyRequestsException": true,
+	"ThrottlingException":      true,
+	"Throttling":               true,
+	"ServiceUnavailable":       true,
+	"RequestTimeout":           true,
+	"InternalError":            true,
+}
+
+// awsClient returns the AWS client for the downloader, with the retries
+// of the AWS SDK disabled, as requests are retried by the downloader.
+func (d *Downloader) awsClient() *amzn.Client {
+	var client amzn.Client
+	if d.AWS != nil {
+		client = *d.AWS
+	}
+	client.DisableRetries = true
+	return &client
+}
+
+// retryPolicy returns the retry policy for the downloader.
+func (d *Downloader) retryPolicy() *RetryPolicy {
+	if d.Retry == nil {
+		return DefaultRetryPolicy
+	}
+	return d.Retry
+}
+
+// statusError is the cause of the error returned when an HTTP
+// request receives an unexpected status.
+type statusError struct {
+	statusCode int
+	status     string
+	retryAfter time.Duration
+}
+
+func (e *statusError) Error() string {
+	return e.status
+}
+
+// newStatusError returns the cause of the error for an unexpected
+// status in the response.
+func newStatusError(response *http.Response) *statusError {
+	return &statusError{
+		statusCode: response.StatusCode,
+		status:     response.Status,
+		retryAfter: parseRetryAfter(response.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter returns the delay requested by a Retry-After header,
+// which contains either a number of seconds or an HTTP date.
+func parseRetryAfter(s string, now time.Time) time.Duration {
+	if s == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(s); err == nil {
+		if seconds < 0 {
+			return 0
+		}
+		return time.Duration(seconds) * time.Second
+	}
+	if t, err := http.ParseTime(s); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// isRetryable reports whether err is a transient error, and the delay
+// requested by the server before retrying, if any.
+func isRetryable(ctx context.Context, err error) (bool, time.Duration) {
+	if ctx.Err() != nil {
+		return false, 0
+	}
+	cause := errors.Cause(err)
+	if se, ok := cause.(*statusError); ok {
+		retry := se.statusCode == http.StatusTooManyRequests || se.statusCode >= 500
+		return retry, se.retryAfter
+	}
+	if awsErr, ok := cause.(awserr.Error); ok {
+		if reqErr, ok := awsErr.(awserr.RequestFailure); ok && reqErr.StatusCode() >= 500 {
+			return true, 0
+		}
+		if throttleCodes[awsErr.Code()] || request.IsErrorThrottle(awsErr) || request.IsErrorRetryable(awsErr) {
+			return true, 0
+		}
+		return false, 0
+	}
+	return isNetworkError(cause), 0
+}
+
+// isNetworkError reports whether err occurred sending a request or
+// reading a response.
+func isNetworkError(err error) bool {
+	if urlErr, ok := err.(*url.Error); ok {
+		err = urlErr.Err
+	}
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return true
+	}
+	var netErr net.Error
+	return stderrors.As(err, &netErr)
+}
+
+// sleep waits for the duration, and returns false if the context
+// is done first.
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
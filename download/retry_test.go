@@ -0,0 +1,240 @@
+package download
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/hclconfig/amzn"
+)
+
+func TestRetryHTTP(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&requests, 1) {
+		case 1:
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case 2:
+			w.Header().Set("Retry-After", "1")
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.Write([]byte(`value = "one"`))
+		}
+	}))
+	defer server.Close()
+
+	d := &Downloader{
+		Retry: &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+	}
+	start := time.Now()
+	file, err := d.Get(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(file.Body), `value = "one"`; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+	if got, want := atomic.LoadInt32(&requests), int32(3); got != want {
+		t.Errorf("requests: got=%d, want=%d", got, want)
+	}
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Errorf("Retry-After not honoured: elapsed=%v", elapsed)
+	}
+}
+
+func TestRetryAfterMaxDelay(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			w.Header().Set("Retry-After", "3600")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`value = "one"`))
+	}))
+	defer server.Close()
+
+	d := &Downloader{
+		Retry: &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond},
+	}
+	if _, err := d.Get(context.Background(), server.URL); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := atomic.LoadInt32(&requests), int32(2); got != want {
+		t.Errorf("requests: got=%d, want=%d", got, want)
+	}
+}
+
+func TestRetryExhausted(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	d := &Downloader{
+		Retry: &RetryPolicy{MaxAttempts: 4, BaseDelay: time.Millisecond, Jitter: 1},
+	}
+	_, err := d.Get(context.Background(), server.URL)
+	if err == nil {
+		t.Fatal("got nil, want error")
+	}
+	if got, want := atomic.LoadInt32(&requests), int32(4); got != want {
+		t.Errorf("requests: got=%d, want=%d", got, want)
+	}
+	for _, want := range []string{"502 Bad Gateway", "attempts=4"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got=%q, want contains %q", err.Error(), want)
+		}
+	}
+}
+
+func TestRetryAWS(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		w.Write([]byte(`{"__type":"ServiceUnavailable","message":"unavailable"}`))
+	}))
+	defer server.Close()
+
+	// the session retries by default, but the downloader disables
+	// the retries of the AWS SDK
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Downloader{
+		AWS:   &amzn.Client{Session: sess},
+		Retry: &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond},
+	}
+	_, err = d.Get(context.Background(), "ssm://myapp/config")
+	if err == nil {
+		t.Fatal("got nil, want error")
+	}
+	if got, want := atomic.LoadInt32(&requests), int32(3); got != want {
+		t.Errorf("requests: got=%d, want=%d", got, want)
+	}
+	if !strings.Contains(err.Error(), "attempts=3") {
+		t.Errorf("got=%q, want attempts=3", err.Error())
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		header string
+	}{
+		{name: "not found", status: http.StatusNotFound},
+		{name: "forbidden with retry after", status: http.StatusForbidden, header: "1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				atomic.AddInt32(&requests, 1)
+				if tt.header != "" {
+					w.Header().Set("Retry-After", tt.header)
+				}
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			d := &Downloader{
+				Retry: &RetryPolicy{MaxAttempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second},
+			}
+			_, err := d.Get(context.Background(), server.URL)
+			if err == nil {
+				t.Fatal("got nil, want error")
+			}
+			if got, want := atomic.LoadInt32(&requests), int32(1); got != want {
+				t.Errorf("requests: got=%d, want=%d", got, want)
+			}
+			if strings.Contains(err.Error(), "attempts=") {
+				t.Errorf("got=%q, want no attempts", err.Error())
+			}
+		})
+	}
+}
+
+func TestRetryNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	d := &Downloader{
+		Retry: &RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+	}
+	_, err := d.Get(context.Background(), url)
+	if err == nil {
+		t.Fatal("got nil, want error")
+	}
+	if !strings.Contains(err.Error(), "attempts=2") {
+		t.Errorf("got=%q, want contains %q", err.Error(), "attempts=2")
+	}
+}
+
+func TestIsRetryable(t *testing.T) {
+	ctx := context.Background()
+	tests := []struct {
+		err  error
+		want bool
+	}{
+		{err: errors.Wrap(awserr.New("SlowDown", "slow down", nil), "cannot download from S3"), want: true},
+		{err: awserr.New("ThrottlingException", "rate exceeded", nil), want: true},
+		{err: awserr.NewRequestFailure(awserr.New("InternalError", "oops", nil), 500, "id"), want: true},
+		{err: awserr.NewRequestFailure(awserr.New("NoSuchKey", "not found", nil), 404, "id"), want: false},
+		{err: awserr.New("AccessDenied", "access denied", nil), want: false},
+		{err: errors.New("cannot open file"), want: false},
+	}
+	for i, tt := range tests {
+		if got, _ := isRetryable(ctx, tt.err); got != tt.want {
+			t.Errorf("%d: %v: got=%v, want=%v", i, tt.err, got, tt.want)
+		}
+	}
+}
+
+func TestRetryDelay(t *testing.T) {
+	p := &RetryPolicy{MaxAttempts: 10, BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	tests := []struct {
+		attempts   int
+		retryAfter time.Duration
+		want       time.Duration
+		ok         bool
+	}{
+		{attempts: 1, want: time.Second, ok: true},
+		{attempts: 2, want: 2 * time.Second, ok: true},
+		{attempts: 3, want: 4 * time.Second, ok: true},
+		{attempts: 4, want: 5 * time.Second, ok: true},
+		{attempts: 2, retryAfter: 3 * time.Second, want: 3 * time.Second, ok: true},
+		{attempts: 2, retryAfter: 6 * time.Second, want: 5 * time.Second, ok: true},
+		{attempts: 10, retryAfter: time.Second, ok: false},
+		{attempts: 10, ok: false},
+	}
+	for _, tt := range tests {
+		got, ok := p.delay(tt.attempts, tt.retryAfter)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("delay(%d, %v): got=%v,%v, want=%v,%v", tt.attempts, tt.retryAfter, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	now := time.Date(2017, 9, 1, 10, 0, 0, 0, time.UTC)
+	if got, want := parseRetryAfter(now.Add(30*time.Second).Format(http.TimeFormat), now), 30*time.Second; got != want {
+		t.Errorf("parseRetryAfter: got=%v, want=%v", got, want)
+	}
+}
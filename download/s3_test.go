@@ -0,0 +1,185 @@
+package download
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/jjeffery/hclconfig/amzn"
+)
+
+// s3Stub is a local S3-compatible server that uses path-style
+// addressing, and records the authorization of the last request.
+type s3Stub struct {
+	mu            sync.Mutex
+	authorization string
+}
+
+func (s *s3Stub) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.authorization = r.Header.Get("Authorization")
+	s.mu.Unlock()
+
+	if r.URL.Path != "/config/app.hcl" {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`<Error><Code>NoSuchKey</Code><Message>not found</Message></Error>`))
+		return
+	}
+	etag, body := `"latest"`, `version = 2`
+	if r.URL.Query().Get("versionId") == "v1" {
+		etag, body = `"v1"`, `version = 1`
+	}
+	if r.Header.Get("If-None-Match") == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", time.Date(2017, 9, 1, 10, 0, 0, 0, time.UTC).Format(http.TimeFormat))
+	w.Write([]byte(body))
+}
+
+func (s *s3Stub) lastAuthorization() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.authorization
+}
+
+func TestGetS3Endpoint(t *testing.T) {
+	stub := &s3Stub{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	sess, err := session.NewSession(&aws.Config{
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("SESSIONKEY", "secret", ""),
+		MaxRetries:  aws.Int(0),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := &Downloader{AWS: &amzn.Client{Session: sess}, Retry: NoRetry}
+	ctx := context.Background()
+	location := "s3://config/app.hcl?endpoint=" + server.URL + "&region=eu-west-2"
+
+	file, err := d.Get(ctx, location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(file.Body), "version = 2"; got != want {
+		t.Errorf("body: got=%q, want=%q", got, want)
+	}
+	if got, want := stub.lastAuthorization(), "/eu-west-2/s3/"; !strings.Contains(got, want) {
+		t.Errorf("authorization: got=%q, want region %q", got, want)
+	}
+
+	file, changed, err := d.GetIfChanged(ctx, location, file.ETag, file.LastModified)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || file != nil {
+		t.Errorf("got changed=%v file=%v, want unchanged", changed, file)
+	}
+
+	file, err = d.Get(ctx, location+"&versionId=v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(file.Body), "version = 1"; got != want {
+		t.Errorf("version: got=%q, want=%q", got, want)
+	}
+
+	head, err := d.Head(ctx, location+"&versionId=v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := head.ETag, `"v1"`; got != want {
+		t.Errorf("head: got=%q, want=%q", got, want)
+	}
+
+	if _, err := d.Get(ctx, "s3://config/missing.hcl?endpoint="+server.URL); err == nil {
+		t.Error("got nil, want error")
+	}
+}
+
+func TestGetS3Profile(t *testing.T) {
+	stub := &s3Stub{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "download")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	credentialsFile := filepath.Join(dir, "credentials")
+	configFile := filepath.Join(dir, "config")
+	ioutil.WriteFile(credentialsFile, []byte("[s3test]\naws_access_key_id = PROFILEKEY\naws_secret_access_key = secret\n"), 0600)
+	ioutil.WriteFile(configFile, []byte("[profile s3test]\nregion = ap-southeast-2\n"), 0600)
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	t.Setenv("AWS_CONFIG_FILE", configFile)
+
+	d := &Downloader{Retry: NoRetry}
+	location := "s3://config/app.hcl?profile=s3test&endpoint=" + server.URL
+	if _, err := d.Get(context.Background(), location); err != nil {
+		t.Fatal(err)
+	}
+	authorization := stub.lastAuthorization()
+	for _, want := range []string{"PROFILEKEY/", "/ap-southeast-2/s3/"} {
+		if !strings.Contains(authorization, want) {
+			t.Errorf("authorization: got=%q, want contains %q", authorization, want)
+		}
+	}
+}
+
+func TestParseS3Location(t *testing.T) {
+	tests := []struct {
+		location string
+		want     amzn.S3Object
+		wantErr  bool
+	}{
+		{
+			location: "s3://bucket/path/app.hcl",
+			want:     amzn.S3Object{Bucket: "bucket", Key: "path/app.hcl"},
+		},
+		{
+			location: "s3://bucket/app.hcl?region=eu-west-1&versionId=abc",
+			want:     amzn.S3Object{Bucket: "bucket", Key: "app.hcl", Region: "eu-west-1", VersionID: "abc"},
+		},
+		{
+			location: "s3://bucket/app.hcl?endpoint=http://localhost:9000",
+			want:     amzn.S3Object{Bucket: "bucket", Key: "app.hcl", Endpoint: "http://localhost:9000", PathStyle: true},
+		},
+		{
+			location: "s3://bucket/app.hcl?endpoint=https://s3.example.com&pathStyle=false",
+			want:     amzn.S3Object{Bucket: "bucket", Key: "app.hcl", Endpoint: "https://s3.example.com"},
+		},
+		{location: "s3://bucket/app.hcl?pathStyle=maybe", wantErr: true},
+		{location: "s3://bucket/app.hcl?regoin=eu-west-1", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseS3Location(tt.location)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: got nil, want error", tt.location)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.location, err)
+			continue
+		}
+		if *got != tt.want {
+			t.Errorf("%s: got=%+v, want=%+v", tt.location, *got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,253 @@
+package download
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/hclconfig/amzn"
+)
+
+// Fetcher downloads files for a URL scheme. Each method is passed the
+// complete location, including the scheme. Implementations must be
+// safe for concurrent use.
+//
+// Fetchers are registered using RegisterScheme, and are used by every
+// Downloader, including the one used by hclconfig.Get and
+// File.HasChanged.
+type Fetcher interface {
+	// Get returns the file at location, including the body.
+	Get(ctx context.Context, location string) (*File, error)
+
+	// Head returns the file at location without the body. The ETag and
+	// last modified time of the file are used to detect changes.
+	Head(ctx context.Context, location string) (*File, error)
+
+	// GetIfChanged returns the file at location, including the body, if
+	// it has changed since the version with the specified ETag and last
+	// modified time. If the file has not changed, it returns a nil file
+	// and changed is false. See File.ChangedSince.
+	GetIfChanged(ctx context.Context, location string, etag string, lastModified time.Time) (file *File, changed bool, err error)
+}
+
+var schemes = struct {
+	sync.RWMutex
+	m map[string]Fetcher
+}{
+	m: make(map[string]Fetcher),
+}
+
+func init() {
+	RegisterScheme("http", &httpFetcher{})
+	RegisterScheme("https", &httpFetcher{})
+	RegisterScheme("s3", &s3Fetcher{})
+	RegisterScheme("ssm", &ssmFetcher{})
+	RegisterScheme("secretsmanager", &secretsFetcher{})
+	RegisterScheme("file", localFetcher{})
+}
+
+// RegisterScheme registers the fetcher used to download locations with
+// the named URL scheme, eg "vault" for "vault://secret/app.hcl". Scheme
+// names are case-insensitive. Registering a fetcher for a built-in
+// scheme ("http", "https", "s3", "ssm", "secretsmanager" or "file")
+// replaces it. Registering a nil fetcher removes any fetcher for the
+// scheme.
+//
+// RegisterScheme is typically called during program initialization.
+func RegisterScheme(name string, fetcher Fetcher) {
+	name = strings.ToLower(name)
+	schemes.Lock()
+	defer schemes.Unlock()
+	if fetcher == nil {
+		delete(schemes.m, name)
+		return
+	}
+	schemes.m[name] = fetcher
+}
+
+func lookupScheme(name string) Fetcher {
+	schemes.RLock()
+	defer schemes.RUnlock()
+	return schemes.m[name]
+}
+
+// configurable is implemented by the built-in fetchers, which use the
+// clients configured in the Downloader.
+type configurable interface {
+	configure(d *Downloader) Fetcher
+}
+
+// fetcher returns the fetcher for the scheme, or nil if the scheme
+// is not registered.
+func (d *Downloader) fetcher(scheme string) Fetcher {
+	fetcher := lookupScheme(scheme)
+	if c, ok := fetcher.(configurable); ok {
+		return c.configure(d)
+	}
+	return fetcher
+}
+
+// httpFetcher downloads HTTP and HTTPS locations.
+type httpFetcher struct {
+	client    *http.Client
+	options   *HTTPOptions
+	locations map[string]*HTTPOptions
+}
+
+func (f *httpFetcher) configure(d *Downloader) Fetcher {
+	return &httpFetcher{
+		client:    d.httpClient(),
+		options:   d.HTTPOptions,
+		locations: d.HTTPLocations,
+	}
+}
+
+func (f *httpFetcher) get(ctx context.Context, location string, includeBody bool, cond *condition) (*File, error) {
+	opts := httpOptions(f.options, f.locations, location)
+	return getHTTP(ctx, f.httpClient(), opts, location, includeBody, cond)
+}
+
+func (f *httpFetcher) httpClient() *http.Client {
+	if f.client == nil {
+		return defaultHTTPClient
+	}
+	return f.client
+}
+
+func (f *httpFetcher) Get(ctx context.Context, location string) (*File, error) {
+	return f.get(ctx, location, true, nil)
+}
+
+func (f *httpFetcher) Head(ctx context.Context, location string) (*File, error) {
+	return f.get(ctx, location, false, nil)
+}
+
+func (f *httpFetcher) GetIfChanged(ctx context.Context, location string, etag string, lastModified time.Time) (*File, bool, error) {
+	return changedResult(f.get(ctx, location, true, &condition{
+		etag:         etag,
+		lastModified: lastModified,
+	}))
+}
+
+// s3Fetcher downloads S3 locations, eg "s3://bucket/key". See
+// parseS3Location for the query parameters that are supported.
+type s3Fetcher struct {
+	client *amzn.Client
+}
+
+func (f *s3Fetcher) configure(d *Downloader) Fetcher {
+	return &s3Fetcher{client: d.awsClient()}
+}
+
+func (f *s3Fetcher) get(ctx context.Context, location string, includeBody bool, cond *condition) (*File, error) {
+	obj, err := parseS3Location(location)
+	if err != nil {
+		return nil, err
+	}
+	return getS3(ctx, f.client, location, obj, includeBody, cond)
+}
+
+func (f *s3Fetcher) Get(ctx context.Context, location string) (*File, error) {
+	return f.get(ctx, location, true, nil)
+}
+
+func (f *s3Fetcher) Head(ctx context.Context, location string) (*File, error) {
+	return f.get(ctx, location, false, nil)
+}
+
+func (f *s3Fetcher) GetIfChanged(ctx context.Context, location string, etag string, lastModified time.Time) (*File, bool, error) {
+	return changedResult(f.get(ctx, location, true, &condition{
+		etag:         etag,
+		lastModified: lastModified,
+	}))
+}
+
+// parseS3Location returns the S3 object at location. The location can
+// have the following query parameters:
+//  region     region of the bucket, eg "eu-west-1"
+//  endpoint   URL of an S3-compatible server, eg "http://localhost:9000"
+//  versionId  version of the object
+//  profile    profile in the AWS shared config and credentials files
+//  pathStyle  "true" for path-style addressing, which is the default
+//             when endpoint is specified
+// For example:
+//  s3://config/app.hcl?endpoint=http://localhost:9000&region=us-east-1
+func parseS3Location(location string) (*amzn.S3Object, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, errors.New("invalid S3 location").With(
+			"location", RedactLocation(location),
+		)
+	}
+	query := u.Query()
+	obj := &amzn.S3Object{
+		Bucket:    u.Host,
+		Key:       strings.TrimPrefix(u.Path, "/"),
+		VersionID: query.Get("versionId"),
+		Region:    query.Get("region"),
+		Endpoint:  query.Get("endpoint"),
+		Profile:   query.Get("profile"),
+		PathStyle: query.Get("endpoint") != "",
+	}
+	for name := range query {
+		switch name {
+		case "versionId", "region", "endpoint", "profile":
+		case "pathStyle":
+			obj.PathStyle, err = strconv.ParseBool(query.Get(name))
+			if err != nil {
+				return nil, errors.New("invalid S3 location: pathStyle must be true or false").With(
+					"location", RedactLocation(location),
+				)
+			}
+		default:
+			return nil, errors.New("invalid S3 location: unknown parameter").With(
+				"location", RedactLocation(location),
+				"parameter", name,
+			)
+		}
+	}
+	return obj, nil
+}
+
+// localFetcher reads files from the local filesystem. Locations can be
+// file paths or "file" URLs.
+type localFetcher struct{}
+
+func (localFetcher) Get(ctx context.Context, location string) (*File, error) {
+	return getLocal(localPath(location), true, nil)
+}
+
+func (localFetcher) Head(ctx context.Context, location string) (*File, error) {
+	return getLocal(localPath(location), false, nil)
+}
+
+func (localFetcher) GetIfChanged(ctx context.Context, location string, etag string, lastModified time.Time) (*File, bool, error) {
+	return changedResult(getLocal(localPath(location), true, &condition{
+		etag:         etag,
+		lastModified: lastModified,
+	}))
+}
+
+// localPath returns the file path of a local location.
+func localPath(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		// not a valid URL, so treat as a file path
+		return location
+	}
+	return u.Path
+}
+
+// changedResult converts the result of a conditional request, where a
+// nil file means the file has not changed, to the result of GetIfChanged.
+func changedResult(file *File, err error) (*File, bool, error) {
+	if err != nil {
+		return nil, false, err
+	}
+	return file, file != nil, nil
+}
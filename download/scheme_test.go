@@ -0,0 +1,72 @@
+package download
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// memFetcher serves a single file from memory, for testing custom schemes.
+type memFetcher struct {
+	file File
+}
+
+func (f *memFetcher) Get(ctx context.Context, location string) (*File, error) {
+	file := f.file
+	return &file, nil
+}
+
+func (f *memFetcher) Head(ctx context.Context, location string) (*File, error) {
+	file := f.file
+	file.Body = nil
+	return &file, nil
+}
+
+func (f *memFetcher) GetIfChanged(ctx context.Context, location string, etag string, lastModified time.Time) (*File, bool, error) {
+	if !f.file.ChangedSince(etag, lastModified) {
+		return nil, false, nil
+	}
+	file := f.file
+	return &file, true, nil
+}
+
+func TestRegisterScheme(t *testing.T) {
+	fetcher := &memFetcher{file: File{Location: "mem://app.hcl", Body: []byte(`value = "one"`), ETag: "1"}}
+	RegisterScheme("MEM", fetcher)
+	defer RegisterScheme("mem", nil)
+
+	ctx := context.Background()
+	file, err := GetContext(ctx, "mem://app.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(file.Body), `value = "one"`; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+
+	file, err = HeadContext(ctx, "mem://app.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if file.Body != nil {
+		t.Errorf("got body %q, want nil", file.Body)
+	}
+
+	file, changed, err := GetIfChanged(ctx, "mem://app.hcl", "1", time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || file != nil {
+		t.Errorf("got changed=%v file=%v, want unchanged", changed, file)
+	}
+
+	d := &Downloader{Schemes: []string{"https"}}
+	if _, err := d.Get(ctx, "mem://app.hcl"); err == nil {
+		t.Error("got nil, want scheme not permitted")
+	}
+
+	RegisterScheme("mem", nil)
+	if _, err := GetContext(ctx, "mem://app.hcl"); err == nil {
+		t.Error("got nil, want unknown scheme")
+	}
+}
@@ -0,0 +1,66 @@
+package hclconfig
+
+import (
+	"os"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+const (
+	// envKey is the key of top-level blocks that contain values
+	// for a specific environment.
+	envKey = "env"
+
+	// envVariable is the name of the environment variable that selects
+	// the environment when Loader.Environment is empty.
+	envVariable = "HCLCONFIG_ENV"
+)
+
+// environment returns the name of the environment selected by the loader.
+func (l *Loader) environment() string {
+	if l.Environment != "" {
+		return l.Environment
+	}
+	return os.Getenv(envVariable)
+}
+
+// selectEnvironment removes the environment blocks from the top level
+// of the file, and merges the contents of the blocks for the named
+// environment over the remaining top-level items. Environment blocks
+// are labelled with the name of the environment:
+//  env "production" { ... }
+// Any other top-level item with the key "env", such as env = "prod" or
+// an unlabelled env block, is an ordinary value and is left alone.
+// If there is more than one block for the environment, they are merged
+// in the order they appear. Environment names are case-insensitive.
+//
+// If name is empty, no environment is selected and the file is left
+// unchanged, so files that use env blocks for other purposes decode
+// as they did before environments were supported.
+func selectEnvironment(node *ast.File, name string) {
+	list, ok := node.Node.(*ast.ObjectList)
+	if !ok || name == "" {
+		return
+	}
+
+	var items []*ast.ObjectItem
+	selected := &ast.File{Node: &ast.ObjectList{}}
+	found := false
+	for _, item := range list.Items {
+		obj, isBlock := item.Val.(*ast.ObjectType)
+		if len(item.Keys) != 2 || !isBlock || keyText(item.Keys[0]) != envKey {
+			items = append(items, item)
+			continue
+		}
+		if strings.EqualFold(keyText(item.Keys[1]), name) {
+			selected = mergeFiles(selected, &ast.File{Node: obj.List})
+			found = true
+		}
+	}
+
+	list.Items = items
+	if found {
+		node.Node = mergeFiles(node, selected).Node
+	}
+}
@@ -0,0 +1,173 @@
+package hclconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLoaderEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.hcl")
+	text := encryptConfig(t, `
+		encryption {
+			test = true
+		}
+		database {
+			hostname = "localhost"
+			port = 5432
+			password = "dev"
+		}
+		env "production" {
+			database {
+				hostname = "prod-db.example.com"
+				password = "prod"
+			}
+		}
+		env "staging" {
+			database {
+				hostname = "staging-db.example.com"
+			}
+		}
+	`)
+	if err := ioutil.WriteFile(filename, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	type config struct {
+		Database struct {
+			Hostname string
+			Port     int
+			Password Secret
+		}
+	}
+
+	tests := []struct {
+		env      string
+		hostname string
+		password string
+	}{
+		{env: "production", hostname: "prod-db.example.com", password: "prod"},
+		{env: "Staging", hostname: "staging-db.example.com", password: "dev"},
+		{env: "test", hostname: "localhost", password: "dev"},
+	}
+	for _, tt := range tests {
+		loader := &Loader{
+			KeyProviders: []KeyProvider{testKeyProvider},
+			Environment:  tt.env,
+		}
+		file, err := loader.Get(filename)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var c config
+		if err := file.DecodeStrict(&c); err != nil {
+			t.Errorf("%s: %v", tt.env, err)
+			continue
+		}
+		if got, want := c.Database.Hostname, tt.hostname; got != want {
+			t.Errorf("%s: hostname: got=%q, want=%q", tt.env, got, want)
+		}
+		if got, want := c.Database.Password.Reveal(), tt.password; got != want {
+			t.Errorf("%s: password: got=%q, want=%q", tt.env, got, want)
+		}
+		if got, want := c.Database.Port, 5432; got != want {
+			t.Errorf("%s: port: got=%d, want=%d", tt.env, got, want)
+		}
+	}
+
+	// the environment variable is used if Environment is empty
+	os.Setenv(envVariable, "production")
+	defer os.Unsetenv(envVariable)
+	file, err := (&Loader{KeyProviders: []KeyProvider{testKeyProvider}}).Get(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := file.GetString("database.hostname"); got != "prod-db.example.com" {
+		t.Errorf("got=%q, err=%v", got, err)
+	}
+}
+
+func TestLoaderNoEnvironment(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.hcl")
+	text := `
+		hostname = "localhost"
+		env "production" {
+			hostname = "prod.example.com"
+		}
+	`
+	if err := ioutil.WriteFile(filename, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// with no environment selected, env blocks are ordinary values
+	os.Unsetenv(envVariable)
+	file, err := (&Loader{}).Get(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var c struct {
+		Hostname string
+		Env      map[string]struct {
+			Hostname string
+		}
+	}
+	if err := file.DecodeStrict(&c); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := c.Hostname, "localhost"; got != want {
+		t.Errorf("hostname: got=%q, want=%q", got, want)
+	}
+	if got, want := c.Env["production"].Hostname, "prod.example.com"; got != want {
+		t.Errorf("env.production.hostname: got=%q, want=%q", got, want)
+	}
+}
+
+func TestLoaderEnvironmentOrdinaryKeys(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	tests := []struct {
+		text string
+		want interface{}
+	}{
+		{text: `env = "prod"`, want: map[string]interface{}{"env": "prod"}},
+		{text: `env { PATH = "/bin" }`, want: map[string]interface{}{"env": []map[string]interface{}{{"PATH": "/bin"}}}},
+	}
+	for _, tt := range tests {
+		filename := filepath.Join(dir, "config.hcl")
+		if err := ioutil.WriteFile(filename, []byte(tt.text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		for _, env := range []string{"", "prod", "PATH"} {
+			file, err := (&Loader{Environment: env}).Get(filename)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got map[string]interface{}
+			if err := file.Decode(&got); err != nil {
+				t.Errorf("%s: %s: %v", tt.text, env, err)
+				continue
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("%s: %s: got=%v, want=%v", tt.text, env, got, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package hclconfig
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/hclconfig/download"
+)
+
+// KeyError describes a problem with a single key in a
+// configuration file.
+type KeyError struct {
+	Location string // location of the file containing the key
+	Key      string // path of the key, eg "database.password"
+	Line     int    // line number, starting at 1
+	Column   int    // column number, starting at 1
+	Message  string // description of the problem
+}
+
+func newKeyError(key string, node ast.Node, msg string) *KeyError {
+	pos := nodePos(node)
+	return &KeyError{
+		Location: pos.Filename,
+		Key:      key,
+		Line:     pos.Line,
+		Column:   pos.Column,
+		Message:  msg,
+	}
+}
+
+// Error implements the error interface.
+func (e *KeyError) Error() string {
+	return errors.New(e.Message).With(
+		"key", e.Key,
+		"line", e.Line,
+		"column", e.Column,
+		"location", download.RedactLocation(e.Location),
+	).Error()
+}
+
+// KeyErrors is a list of problems with keys in a configuration file.
+// It is returned when more than one problem can be reported at once.
+type KeyErrors []*KeyError
+
+// Error implements the error interface.
+func (e KeyErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// setDefaultLocation sets the location of errors that do not have one.
+// Errors have the location of the file that their key was loaded from,
+// which for included and layered files differs from the location of
+// the merged file.
+func (e KeyErrors) setDefaultLocation(location string) {
+	for _, err := range e {
+		if err.Location == "" {
+			err.Location = location
+		}
+	}
+}
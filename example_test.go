@@ -1,6 +1,10 @@
 package hclconfig
 
-import "log"
+import (
+	"context"
+	"log"
+	"time"
+)
 
 func ExampleGet() {
 	// get a config file from a HTTP URL
@@ -60,4 +64,24 @@ func ExampleFile_Decode() {
 	*/
 }
 
+func ExampleFile_Watch() {
+	file, err := Get("s3://bucket-name/config/file.hcl")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// check for changes every minute until ctx is cancelled
+	file.Watch(ctx, time.Minute, func(newFile *File, err error) {
+		if err != nil {
+			// transient errors do not stop the watch
+			log.Println("cannot reload config:", err)
+			return
+		}
+		doSomethingWith(newFile)
+	})
+}
+
 func doSomethingWith(v interface{}) {}
@@ -1,49 +1,77 @@
 package hclconfig
 
 import (
+	"context"
+	"sync/atomic"
 	"time"
 
-	"github.com/hashicorp/hcl"
 	"github.com/hashicorp/hcl/hcl/ast"
-	"github.com/jjeffery/errors"
-	"github.com/jjeffery/hclconfig/amzn"
-	"github.com/jjeffery/hclconfig/astcrypt"
 	"github.com/jjeffery/hclconfig/download"
 )
 
 // Get downloads the configuration file from the location, parses it
 // and decrypts any sensitive data.
-// The location can be a HTTP/HTTPS URL, an S3 URL, or a local
-// file path.
+// The location can be a HTTP/HTTPS URL, an S3 URL, an SSM parameter
+// (eg "ssm://myapp/config"), a Secrets Manager secret (eg
+// "secretsmanager://myapp/config"), or a local file path.
+//
+// A configuration file can include other files using a top-level
+// include directive:
+//  include = ["s3://bucket/common.hcl", "./db.hcl"]
+// Relative locations are resolved against the location of the including
+// file. Included files are merged in order (see GetLayered), and the
+// including file takes precedence over the files it includes. Ciphertext
+// in included files is decrypted using the data key of the merged file.
+//
+// Values for a specific environment can be placed in env blocks:
+//  env "production" {
+//      database { hostname = "prod-db.example.com" }
+//  }
+// The environment is selected by the HCLCONFIG_ENV environment variable,
+// or by the Environment field of a Loader. The blocks for the selected
+// environment are merged over the top-level values after decryption.
 func Get(location string) (*File, error) {
-	d, err := download.Get(location)
-	if err != nil {
-		return nil, err
-	}
-	node, err := hcl.ParseBytes(d.Body)
-	if err != nil {
-		return nil, errors.Wrap(err).With(
-			"location", location,
-		)
-	}
-	decrypter, err := amzn.NewKey(node)
-	if err != nil {
-		return nil, errors.Wrap(err).With(
-			"location", location,
-		)
-	}
-	if err = astcrypt.Decrypt(node, decrypter); err != nil {
-		return nil, errors.Wrap(err).With(
-			"location", location,
-		)
-	}
-	f := &File{
-		Location:     location,
-		Etag:         d.ETag,
-		LastModified: d.LastModified,
-		Contents:     node,
-	}
-	return f, nil
+	return defaultLoader.GetContext(context.Background(), location)
+}
+
+// GetContext is like Get, but cancelling the context aborts any
+// download or key decryption that is in progress.
+func GetContext(ctx context.Context, location string) (*File, error) {
+	return defaultLoader.GetContext(ctx, location)
+}
+
+// GetWithFallback is like Get, but if the file cannot be downloaded from
+// location, each of the fallback locations is tried in order. If no
+// location succeeds, the error for the first location is returned.
+//
+// To also fall back to the last version of the file that was downloaded
+// successfully, use a Loader with a cache directory.
+func GetWithFallback(location string, fallbacks ...string) (*File, error) {
+	return defaultLoader.GetWithFallbackContext(context.Background(), location, fallbacks...)
+}
+
+// GetWithFallbackContext is like GetWithFallback, but cancelling the context
+// aborts any download or key decryption that is in progress.
+func GetWithFallbackContext(ctx context.Context, location string, fallbacks ...string) (*File, error) {
+	return defaultLoader.GetWithFallbackContext(ctx, location, fallbacks...)
+}
+
+// GetLayered downloads, parses and decrypts the configuration file at each
+// of the locations, and merges them into a single file. Each location can
+// have its own encryption block.
+//
+// Later locations take precedence over earlier locations. Blocks that
+// appear once in each location are merged key by key. Any other value
+// in a later location, including a repeated block, replaces the value
+// with the same key in an earlier location.
+func GetLayered(locations ...string) (*File, error) {
+	return defaultLoader.GetLayeredContext(context.Background(), locations...)
+}
+
+// GetLayeredContext is like GetLayered, but cancelling the context aborts
+// any download or key decryption that is in progress.
+func GetLayeredContext(ctx context.Context, locations ...string) (*File, error) {
+	return defaultLoader.GetLayeredContext(ctx, locations...)
 }
 
 // File represents a configuration file that has been loaded
@@ -53,34 +81,221 @@ type File struct {
 	Etag         string
 	LastModified time.Time
 	Contents     *ast.File
+
+	// FromCache is true if the file, or any file that it includes, could
+	// not be downloaded and was read from the loader's cache directory
+	// instead. See Loader.CacheDir.
+	FromCache bool
+
+	// Layers contains the files that were merged to create this file,
+	// in order of precedence from lowest to highest. It is only set for
+	// files loaded using GetLayered.
+	Layers []*File
+
+	// loader is the loader that loaded the file
+	loader *Loader
+
+	// includes contains the version of each included file,
+	// without the body
+	includes []*download.File
+
+	// decrypted contains the values in Contents that were
+	// encrypted in the configuration file
+	decrypted map[*ast.LiteralType]bool
+
+	// fingerprintKey is derived from the data key, and is used to
+	// fingerprint decrypted values without revealing them
+	fingerprintKey []byte
+
+	// refreshed is the time in Unix nanoseconds that the file was loaded,
+	// or last successfully refreshed
+	refreshed atomic.Int64
+}
+
+// RefreshedAt returns the time that the file was loaded, or the time
+// of the last successful call to Refresh that found it unchanged.
+func (f *File) RefreshedAt() time.Time {
+	return time.Unix(0, f.refreshed.Load())
 }
 
-// HasChanged returns true if the config file has changed. It
-// does not download the new contents.
+// HasChanged returns true if the config file, or any file that it
+// includes, has changed. It does not download the new contents.
 //
 // For HTTP(S) and S3 URLs, this function performs a HEAD operation
 // and compares the ETag or the Last-Modified headers. For local files
 // this function performs a file stat and compares the last modified times.
 func (f *File) HasChanged() (bool, error) {
-	d, err := download.Head(f.Location)
+	return f.HasChangedContext(context.Background())
+}
+
+// HasChangedContext is like HasChanged, but cancelling the context
+// aborts the request.
+//
+// For a file loaded using GetLayered, HasChangedContext returns
+// true if any of the layers has changed.
+func (f *File) HasChangedContext(ctx context.Context) (bool, error) {
+	start := time.Now()
+	changed, err := f.hasChanged(ctx)
+	if observer := loaderOrDefault(f.loader).Observer; observer != nil {
+		observer.ChangeCheck(ChangeCheckEvent{
+			Location: download.RedactLocation(f.Location),
+			Changed:  changed,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+	}
+	return changed, err
+}
+
+func (f *File) hasChanged(ctx context.Context) (bool, error) {
+	if len(f.Layers) > 0 {
+		for _, layer := range f.Layers {
+			changed, err := layer.hasChanged(ctx)
+			if err != nil || changed {
+				return changed, err
+			}
+		}
+		return false, nil
+	}
+	downloader := loaderOrDefault(f.loader).downloader()
+	d, err := downloader.Head(ctx, f.Location)
 	if err != nil {
 		return false, err
 	}
-	if d.ETag != "" && f.Etag != "" {
-		// if both have ETags, the file has changed if they are not equal
-		return d.ETag != f.Etag, nil
+	if d.ChangedSince(f.Etag, f.LastModified) {
+		return true, nil
+	}
+	return f.includesChanged(ctx)
+}
+
+// includesChanged returns true if any of the files included by
+// f have changed.
+func (f *File) includesChanged(ctx context.Context) (bool, error) {
+	downloader := loaderOrDefault(f.loader).downloader()
+	for _, include := range f.includes {
+		d, err := downloader.Head(ctx, include.Location)
+		if err != nil {
+			return false, err
+		}
+		if d.ChangedSince(include.ETag, include.LastModified) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// Refresh checks whether the configuration file has changed and, if it
+// has, downloads, parses and decrypts the new version. It returns the new
+// file and true if the file has changed, or f and false if it has not.
+//
+// Unlike calling HasChanged followed by Get, Refresh uses a single
+// conditional request, so there is no race between checking for a change
+// and downloading it. For HTTP(S) URLs the request has If-None-Match and
+// If-Modified-Since headers, for S3 URLs the request has IfNoneMatch, and
+// for local files a file stat is performed before reading the file.
+//
+// Files included by the configuration file are checked using HasChanged.
+func (f *File) Refresh() (*File, bool, error) {
+	return f.RefreshContext(context.Background())
+}
+
+// RefreshContext is like Refresh, but cancelling the context aborts
+// the request.
+func (f *File) RefreshContext(ctx context.Context) (*File, bool, error) {
+	start := time.Now()
+	newFile, changed, err := f.refresh(ctx)
+	if err == nil {
+		newFile.refreshed.Store(time.Now().UnixNano())
+	}
+	if observer := loaderOrDefault(f.loader).Observer; observer != nil {
+		observer.Reload(ReloadEvent{
+			Location: download.RedactLocation(f.Location),
+			Changed:  changed,
+			Duration: time.Since(start),
+			Err:      err,
+		})
+	}
+	return newFile, changed, err
+}
+
+func (f *File) refresh(ctx context.Context) (*File, bool, error) {
+	loader := loaderOrDefault(f.loader)
+	if len(f.Layers) > 0 {
+		layers := make([]*File, len(f.Layers))
+		var changed bool
+		for i, layer := range f.Layers {
+			newLayer, layerChanged, err := layer.refresh(ctx)
+			if err != nil {
+				return nil, false, err
+			}
+			layers[i] = newLayer
+			changed = changed || layerChanged
+		}
+		if !changed {
+			return f, false, nil
+		}
+		return loader.merge(layers), true, nil
 	}
 
-	return d.LastModified.After(f.LastModified), nil
+	d, changed, err := loader.downloader().GetIfChanged(ctx, f.Location, f.Etag, f.LastModified)
+	if err != nil {
+		return nil, false, err
+	}
+	if !changed {
+		changed, err = f.includesChanged(ctx)
+		if err != nil {
+			return nil, false, err
+		}
+		if !changed {
+			return f, false, nil
+		}
+		newFile, err := loader.GetContext(ctx, f.Location)
+		if err != nil {
+			return nil, false, err
+		}
+		return newFile, true, nil
+	}
+	newFile, err := loader.load(ctx, f.Location, d, false)
+	if err != nil {
+		return nil, false, err
+	}
+	return newFile, true, nil
 }
 
 // Decode decodes the contents of the configuration file into the
-// structure pointed to by v.
+// structure pointed to by v. Decoding follows the same rules as
+// hcl.DecodeObject, with the following additions:
+//  - time.Duration fields are decoded from strings such as "30s"
+//  - types that implement encoding.TextUnmarshaler, such as net.IP
+//    and time.Time, are decoded from string values
+//  - url.URL fields are decoded from strings
+//  - types with a hook registered using RegisterDecodeHook are
+//    decoded using the hook
+//
+// A struct field can specify a default value, which is used when the key
+// is not present in the configuration file, and validation rules:
+//  Port int `hclconfig:"default=5432" validate:"min=1,max=65535"`
+//  Mode string `validate:"required,oneof=read write"`
+// Decode also calls the Validate method of every decoded value that
+// implements the Validator interface.
+//
+// Fields of type Secret can only be decoded from values that were
+// encrypted in the configuration file.
+//
+// If any value cannot be decoded or is not valid, Decode returns an
+// error of type KeyErrors, which contains the path, line and column of
+// every problem found.
 func (f *File) Decode(v interface{}) error {
-	if err := hcl.DecodeObject(v, f.Contents); err != nil {
-		return errors.Wrap(err).With(
-			"location", f.Location,
-		)
-	}
-	return nil
+	return f.decode(v, false)
+}
+
+// DecodeStrict is like Decode, but also returns an error if the
+// configuration file contains any keys that do not correspond to
+// a field in the structure pointed to by v. This detects misspelled
+// keys. The top-level encryption block is ignored.
+//
+// If there are unused keys, the error is of type KeyErrors, and
+// contains the path and line number of every unused key.
+func (f *File) DecodeStrict(v interface{}) error {
+	return f.decode(v, true)
 }
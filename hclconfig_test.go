@@ -0,0 +1,142 @@
+package hclconfig
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jjeffery/hclconfig/download"
+)
+
+func TestRefresh(t *testing.T) {
+	version := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := fmt.Sprintf(`"%d"`, version)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("Etag", etag)
+		fmt.Fprintf(w, "version = %d", version)
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	loader := &Loader{
+		HTTPClient: &http.Client{Transport: transport},
+	}
+	file, err := loader.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newFile, changed, err := file.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed || newFile != file {
+		t.Errorf("got changed=%v, want unchanged", changed)
+	}
+
+	version = 2
+	newFile, changed, err = file.Refresh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Fatal("got unchanged, want changed")
+	}
+	var config struct {
+		Version int
+	}
+	if err := newFile.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Version, 2; got != want {
+		t.Errorf("got=%d, want=%d", got, want)
+	}
+
+	// one request for Get and one for each Refresh
+	if got, want := transport.count, 3; got != want {
+		t.Errorf("requests: got=%d, want=%d", got, want)
+	}
+}
+
+func TestCustomScheme(t *testing.T) {
+	fetcher := &memFetcher{version: 1}
+	download.RegisterScheme("test-mem", fetcher)
+	defer download.RegisterScheme("test-mem", nil)
+
+	file, err := Get("test-mem://app.hcl")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Version int `hcl:"version"`
+	}
+	if err := file.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Version, 1; got != want {
+		t.Errorf("got=%d, want=%d", got, want)
+	}
+
+	changed, err := file.HasChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("got changed, want unchanged")
+	}
+
+	fetcher.setVersion(2)
+	changed, err = file.HasChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("got unchanged, want changed")
+	}
+}
+
+// memFetcher serves a single versioned file from memory.
+type memFetcher struct {
+	mu        sync.Mutex
+	version   int
+	sensitive bool
+}
+
+func (f *memFetcher) setVersion(version int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.version = version
+}
+
+func (f *memFetcher) Get(ctx context.Context, location string) (*download.File, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return &download.File{
+		Location:  location,
+		Body:      []byte(fmt.Sprintf("version = %d", f.version)),
+		ETag:      fmt.Sprint(f.version),
+		Sensitive: f.sensitive,
+	}, nil
+}
+
+func (f *memFetcher) Head(ctx context.Context, location string) (*download.File, error) {
+	file, _ := f.Get(ctx, location)
+	file.Body = nil
+	return file, nil
+}
+
+func (f *memFetcher) GetIfChanged(ctx context.Context, location string, etag string, lastModified time.Time) (*download.File, bool, error) {
+	file, _ := f.Get(ctx, location)
+	if !file.ChangedSince(etag, lastModified) {
+		return nil, false, nil
+	}
+	return file, true, nil
+}
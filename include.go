@@ -0,0 +1,205 @@
+package hclconfig
+
+import (
+	"context"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/hclconfig/download"
+)
+
+// includeKey is the key of the top-level item that lists the
+// locations of files to include.
+const includeKey = "include"
+
+// includer resolves include directives for a single call to Get.
+type includer struct {
+	loader *Loader
+
+	// files contains every file that has been included, so that
+	// changes to included files can be detected
+	files []*download.File
+
+	// downloaded contains the included files that were downloaded,
+	// including the body, so that they can be stored in the loader's
+	// cache directory once the including file has been loaded
+	downloaded []*download.File
+
+	// fromCache is true if any included file was read from
+	// the loader's cache directory
+	fromCache bool
+
+	// decrypted receives the values of included files whose body
+	// was decrypted by the server
+	decrypted map[*ast.LiteralType]bool
+}
+
+// resolve downloads any files included by the node and merges them
+// into it. The location is where the node was loaded from, and is used
+// for resolving relative locations. The stack contains the locations
+// of the files currently being included, and is used to detect cycles.
+func (inc *includer) resolve(ctx context.Context, node *ast.File, location string, stack []string) error {
+	list, ok := node.Node.(*ast.ObjectList)
+	if !ok {
+		return nil
+	}
+
+	var includes []string
+	var items []*ast.ObjectItem
+	for _, item := range list.Items {
+		if len(item.Keys) != 1 || keyText(item.Keys[0]) != includeKey {
+			items = append(items, item)
+			continue
+		}
+		locations, err := includeLocations(item)
+		if err != nil {
+			return errors.Wrap(err).With(
+				"location", download.RedactLocation(location),
+			)
+		}
+		includes = append(includes, locations...)
+	}
+	if len(includes) == 0 {
+		return nil
+	}
+
+	stack = append(stack[:len(stack):len(stack)], cleanLocation(location))
+	merged := &ast.File{Node: &ast.ObjectList{}}
+	for _, include := range includes {
+		include = resolveLocation(location, include)
+		for _, loc := range stack {
+			if loc == cleanLocation(include) {
+				var cycle []string
+				for _, loc := range append(stack, include) {
+					cycle = append(cycle, download.RedactLocation(loc))
+				}
+				return errors.New("include cycle detected").With(
+					"location", download.RedactLocation(location),
+					"include", download.RedactLocation(include),
+					"cycle", strings.Join(cycle, " -> "),
+				)
+			}
+		}
+		d, fromCache, err := inc.loader.downloadOrCache(ctx, include)
+		if err != nil {
+			return err
+		}
+		inc.fromCache = inc.fromCache || fromCache
+		child, err := hcl.ParseBytes(d.Body)
+		if err != nil {
+			return errors.Wrap(err).With(
+				"location", download.RedactLocation(include),
+			)
+		}
+		setFilename(child, include)
+		if d.Sensitive && inc.decrypted != nil {
+			markDecrypted(child, inc.decrypted)
+		}
+		if !fromCache {
+			inc.downloaded = append(inc.downloaded, d)
+		}
+		version := *d
+		version.Body = nil
+		inc.files = append(inc.files, &version)
+		if err := inc.resolve(ctx, child, include, stack); err != nil {
+			return err
+		}
+		merged = mergeFiles(merged, child)
+	}
+
+	// items in the including file take precedence over included items
+	list.Items = items
+	merged = mergeFiles(merged, node)
+	node.Node = merged.Node
+	node.Comments = merged.Comments
+	return nil
+}
+
+// includeLocations returns the locations listed in an include item,
+// which can be a single string or a list of strings.
+func includeLocations(item *ast.ObjectItem) ([]string, error) {
+	var nodes []ast.Node
+	switch val := item.Val.(type) {
+	case *ast.LiteralType:
+		nodes = append(nodes, val)
+	case *ast.ListType:
+		nodes = val.List
+	}
+
+	var locations []string
+	for _, node := range nodes {
+		lit, ok := node.(*ast.LiteralType)
+		if !ok || lit.Token.Type != token.STRING {
+			return nil, errors.New("include must be a string or a list of strings").With(
+				"line", node.Pos().Line,
+				"column", node.Pos().Column,
+			)
+		}
+		locations = append(locations, lit.Token.Value().(string))
+	}
+	if len(nodes) == 0 {
+		return nil, errors.New("include must be a string or a list of strings").With(
+			"line", item.Pos().Line,
+			"column", item.Pos().Column,
+		)
+	}
+	return locations, nil
+}
+
+// resolveLocation resolves the location of an included file relative
+// to the location of the file that includes it. A relative include of an
+// S3 location has the query parameters of the including location, such
+// as region and endpoint, unless it sets them itself. The versionId
+// parameter is not carried over, as it identifies a version of the
+// including file.
+func resolveLocation(base, ref string) string {
+	if isURL(ref) {
+		return ref
+	}
+	if isURL(base) {
+		baseURL, err := url.Parse(base)
+		if err != nil {
+			return ref
+		}
+		refURL, err := url.Parse(ref)
+		if err != nil {
+			return ref
+		}
+		resolved := baseURL.ResolveReference(refURL)
+		if strings.EqualFold(baseURL.Scheme, "s3") {
+			query := resolved.Query()
+			for name, values := range baseURL.Query() {
+				if name == "versionId" || query.Get(name) != "" {
+					continue
+				}
+				query[name] = values
+			}
+			resolved.RawQuery = query.Encode()
+		}
+		return resolved.String()
+	}
+	if filepath.IsAbs(ref) {
+		return ref
+	}
+	return filepath.Join(filepath.Dir(base), ref)
+}
+
+// cleanLocation returns the shortest equivalent location, so that
+// locations can be compared when detecting include cycles.
+func cleanLocation(location string) string {
+	if isURL(location) {
+		return location
+	}
+	return filepath.Clean(location)
+}
+
+// isURL reports whether the location is a URL, as opposed to
+// a local file path.
+func isURL(location string) bool {
+	return strings.Contains(location, "://")
+}
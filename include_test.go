@@ -0,0 +1,176 @@
+package hclconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestInclude(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "db"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	modTime := time.Now().Add(-time.Hour)
+	writeConfig(t, filepath.Join(dir, "common.hcl"), `
+		encryption {
+			test = true
+		}
+		name = "common"
+		timeout = 30
+	`, modTime)
+	writeConfig(t, filepath.Join(dir, "db", "db.hcl"), encryptConfig(t, `
+		include = "../common.hcl"
+		database {
+			hostname = "db.example.com"
+			password = "s3cret"
+		}
+	`), modTime)
+	main := filepath.Join(dir, "main.hcl")
+	writeConfig(t, main, `
+		include = ["./common.hcl", "db/db.hcl"]
+		name = "main"
+	`, modTime)
+
+	loader := &Loader{
+		KeyProviders: []KeyProvider{testKeyProvider},
+	}
+	file, err := loader.Get(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		Name     string
+		Timeout  int
+		Database struct {
+			Hostname string
+			Password string
+		}
+	}
+	if err := file.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Name, "main"; got != want {
+		t.Errorf("name: got=%q, want=%q", got, want)
+	}
+	if got, want := config.Timeout, 30; got != want {
+		t.Errorf("timeout: got=%d, want=%d", got, want)
+	}
+	if got, want := config.Database.Password, "s3cret"; got != want {
+		t.Errorf("password: got=%q, want=%q", got, want)
+	}
+
+	changed, err := file.HasChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("got changed, want unchanged")
+	}
+	writeConfig(t, filepath.Join(dir, "common.hcl"), `timeout = 60`, modTime.Add(time.Minute))
+	changed, err = file.HasChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("got unchanged, want changed")
+	}
+}
+
+func TestIncludeCycle(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	modTime := time.Now()
+	writeConfig(t, filepath.Join(dir, "a.hcl"), `include = "b.hcl"`, modTime)
+	writeConfig(t, filepath.Join(dir, "b.hcl"), `include = "./a.hcl"`, modTime)
+
+	_, err = Get(filepath.Join(dir, "a.hcl"))
+	if err == nil {
+		t.Fatal("got nil, want error")
+	}
+	if !strings.Contains(err.Error(), "include cycle") {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestIncludeKeyErrorLocation(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	main := filepath.Join(dir, "main.hcl")
+	db := filepath.Join(dir, "db.hcl")
+	writeConfig(t, main, "include = \"db.hcl\"\nname = \"app\"\n", time.Now())
+	writeConfig(t, db, "\n\nport = \"eighty\"\n", time.Now())
+
+	file, err := Get(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Name string
+		Port int
+	}
+	for _, decode := range []func() error{
+		func() error { return file.Decode(&config) },
+		func() error { return file.DecodePath("port", &config.Port) },
+	} {
+		errs, ok := decode().(KeyErrors)
+		if !ok || len(errs) != 1 {
+			t.Fatalf("got %v, want one KeyError", errs)
+		}
+		if got, want := errs[0].Location, db; got != want {
+			t.Errorf("location: got=%q, want=%q", got, want)
+		}
+		if got, want := errs[0].Line, 3; got != want {
+			t.Errorf("line: got=%d, want=%d", got, want)
+		}
+	}
+}
+
+func TestResolveLocation(t *testing.T) {
+	tests := []struct {
+		base string
+		ref  string
+		want string
+	}{
+		{"s3://bucket/config/main.hcl", "db.hcl", "s3://bucket/config/db.hcl"},
+		{"s3://bucket/config/main.hcl", "../common.hcl", "s3://bucket/common.hcl"},
+		{
+			"s3://bucket/app.hcl?endpoint=http://localhost:9000&region=us-east-1&versionId=3",
+			"./db.hcl",
+			"s3://bucket/db.hcl?endpoint=http%3A%2F%2Flocalhost%3A9000&region=us-east-1",
+		},
+		{
+			"s3://bucket/app.hcl?region=us-east-1&profile=dev",
+			"db.hcl?region=eu-west-1",
+			"s3://bucket/db.hcl?profile=dev&region=eu-west-1",
+		},
+		{"https://example.com/a/main.hcl?token=x", "db.hcl", "https://example.com/a/db.hcl"},
+		{"https://example.com/a/main.hcl", "/b/db.hcl", "https://example.com/b/db.hcl"},
+		{"https://example.com/a/main.hcl", "s3://bucket/db.hcl", "s3://bucket/db.hcl"},
+		{"/etc/app/main.hcl", "db.hcl", "/etc/app/db.hcl"},
+		{"/etc/app/main.hcl", "/etc/common.hcl", "/etc/common.hcl"},
+		{"config/main.hcl", "./db.hcl", "config/db.hcl"},
+	}
+	for _, tt := range tests {
+		if got, want := resolveLocation(tt.base, tt.ref), tt.want; got != want {
+			t.Errorf("%s, %s: got=%q, want=%q", tt.base, tt.ref, got, want)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package hclconfig
+
+import (
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/jjeffery/errors"
+)
+
+// interpolate replaces references in the string values of the file
+// with the values they refer to. The following forms are supported:
+//  ${env.NAME}             value of environment variable NAME
+//  ${env.NAME:-default}    as above, or "default" if NAME is unset or empty
+//  ${database.hostname}    value of another key in the same file
+//  $${                     literal "${"
+// References to other keys are paths, as described in File.DecodePath,
+// eg ${service.web.port} or ${servers[0].hostname}. Any reference that
+// cannot be resolved is an error.
+//
+// A value that refers to a value in decrypted is added to decrypted, so
+// that it is treated as a secret.
+func interpolate(file *ast.File, lookupEnv func(string) (string, bool), decrypted map[*ast.LiteralType]bool) error {
+	if _, ok := file.Node.(*ast.ObjectList); !ok {
+		return nil
+	}
+	ip := interpolator{
+		file:      &File{Contents: file},
+		lookupEnv: lookupEnv,
+		decrypted: decrypted,
+		state:     make(map[*ast.LiteralType]int),
+	}
+	if ip.decrypted == nil {
+		ip.decrypted = make(map[*ast.LiteralType]bool)
+	}
+	if ip.lookupEnv == nil {
+		ip.lookupEnv = os.LookupEnv
+	}
+
+	var literals []*ast.LiteralType
+	ast.Walk(file, func(node ast.Node) (ast.Node, bool) {
+		if lit, ok := node.(*ast.LiteralType); ok {
+			literals = append(literals, lit)
+		}
+		return node, true
+	})
+
+	for _, lit := range literals {
+		if err := ip.expand(lit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// states for interpolating literals
+const (
+	stateExpanding = 1
+	stateExpanded  = 2
+)
+
+type interpolator struct {
+	file      *File
+	lookupEnv func(string) (string, bool)
+	decrypted map[*ast.LiteralType]bool
+	state     map[*ast.LiteralType]int
+}
+
+// expand interpolates a single literal value in place. The literal is
+// modified rather than replaced, so that other references to it remain
+// valid.
+func (ip *interpolator) expand(lit *ast.LiteralType) error {
+	if lit.Token.Type != token.STRING && lit.Token.Type != token.HEREDOC {
+		return nil
+	}
+	switch ip.state[lit] {
+	case stateExpanded:
+		return nil
+	case stateExpanding:
+		return errors.New("circular reference").With(
+			"line", lit.Token.Pos.Line,
+			"column", lit.Token.Pos.Column,
+		)
+	}
+	ip.state[lit] = stateExpanding
+
+	value := lit.Token.Value().(string)
+	if !strings.Contains(value, "${") {
+		ip.state[lit] = stateExpanded
+		return nil
+	}
+
+	var result []string
+	for {
+		i := strings.Index(value, "${")
+		if i < 0 {
+			break
+		}
+		if i > 0 && value[i-1] == '$' {
+			// escaped: "$${" is a literal "${"
+			result = append(result, value[:i-1], "${")
+			value = value[i+2:]
+			continue
+		}
+		j := strings.Index(value[i:], "}")
+		if j < 0 {
+			return errors.New("unterminated reference").With(
+				"line", lit.Token.Pos.Line,
+				"column", lit.Token.Pos.Column,
+			)
+		}
+		expr := strings.TrimSpace(value[i+2 : i+j])
+		resolved, secret, err := ip.resolve(expr)
+		if secret {
+			ip.decrypted[lit] = true
+		}
+		if err != nil {
+			return errors.Wrap(err).With(
+				"reference", expr,
+				"line", lit.Token.Pos.Line,
+				"column", lit.Token.Pos.Column,
+			)
+		}
+		result = append(result, value[:i], resolved)
+		value = value[i+j+1:]
+	}
+	result = append(result, value)
+
+	// The value is quoted as a JSON string so that it is unquoted using
+	// strconv.Unquote, which does not treat "${" as special.
+	lit.Token.Type = token.STRING
+	lit.Token.JSON = true
+	lit.Token.Text = strconv.Quote(strings.Join(result, ""))
+	ip.state[lit] = stateExpanded
+	return nil
+}
+
+// resolve returns the value of a single reference expression, and
+// whether the value is a decrypted secret.
+func (ip *interpolator) resolve(expr string) (string, bool, error) {
+	if strings.HasPrefix(expr, "env.") {
+		name := strings.TrimPrefix(expr, "env.")
+		var defaultValue *string
+		if i := strings.Index(name, ":-"); i >= 0 {
+			s := name[i+2:]
+			defaultValue = &s
+			name = name[:i]
+		}
+		value, ok := ip.lookupEnv(name)
+		if (!ok || value == "") && defaultValue != nil {
+			return *defaultValue, false, nil
+		}
+		if !ok {
+			return "", false, errors.New("environment variable not set")
+		}
+		return value, false, nil
+	}
+
+	filter, err := ip.file.lookupPath(expr)
+	if err != nil {
+		return "", false, errors.New("cannot resolve reference")
+	}
+	items := filter.Elem().Items
+	if len(items) != 1 {
+		return "", false, errors.New("reference is not a simple value")
+	}
+	lit, ok := items[0].Val.(*ast.LiteralType)
+	if !ok {
+		return "", false, errors.New("reference is not a simple value")
+	}
+	if err := ip.expand(lit); err != nil {
+		return "", false, err
+	}
+	secret := ip.decrypted[lit]
+	if s, ok := lit.Token.Value().(string); ok {
+		return s, secret, nil
+	}
+	return lit.Token.Text, secret, nil
+}
@@ -0,0 +1,201 @@
+package hclconfig
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+func TestInterpolate(t *testing.T) {
+	env := map[string]string{
+		"HOSTNAME": "host1",
+		"EMPTY":    "",
+	}
+	lookupEnv := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		text string
+		want string
+		err  string
+	}{
+		{text: `value = "${env.HOSTNAME}-worker"`, want: "host1-worker"},
+		{text: `value = "${ env.HOSTNAME }"`, want: "host1"},
+		{text: `value = "${env.PORT:-8080}"`, want: "8080"},
+		{text: `value = "${env.EMPTY:-default}"`, want: "default"},
+		{text: `value = "${env.HOSTNAME:-default}"`, want: "host1"},
+		{text: `value = "$${env.HOSTNAME}"`, want: "${env.HOSTNAME}"},
+		{text: `value = "no references"`, want: "no references"},
+		{
+			text: `
+				database {
+					hostname = "db-${env.HOSTNAME}"
+					port = 5432
+				}
+				value = "${database.hostname}:${database.port}"
+			`,
+			want: "db-host1:5432",
+		},
+		{
+			text: `
+				service "web" {
+					name = "web"
+				}
+				value = "${service.web.name}"
+			`,
+			want: "web",
+		},
+		{
+			text: `
+				service "web" {
+					port = 80
+				}
+				service "api" {
+					port = 8080
+				}
+				value = "${service.web.port},${service.api.port}"
+			`,
+			want: "80,8080",
+		},
+		{
+			text: `
+				servers = ["a", "b"]
+				value = "${servers[1]}"
+			`,
+			want: "b",
+		},
+		{text: `value = "${env.MISSING}"`, err: "line=1"},
+		{text: `value = "${missing.key}"`, err: "cannot resolve reference"},
+		{text: "value = <<END\n${env.HOSTNAME\nEND\n", err: "unterminated reference"},
+		{
+			text: `
+				a = "${b}"
+				b = "${a}"
+				value = "${a}"
+			`,
+			err: "circular reference",
+		},
+	}
+
+	for _, tt := range tests {
+		node, err := hcl.ParseString(tt.text)
+		if err != nil {
+			t.Errorf("%s: %v", tt.text, err)
+			continue
+		}
+		err = interpolate(node, lookupEnv, nil)
+		if tt.err != "" {
+			if err == nil {
+				t.Errorf("%s: got nil, want error", tt.text)
+			} else if !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: got=%v, want=%s", tt.text, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.text, err)
+			continue
+		}
+		var config struct {
+			Value string
+		}
+		if err := hcl.DecodeObject(&config, node); err != nil {
+			t.Errorf("%s: %v", tt.text, err)
+			continue
+		}
+		if got, want := config.Value, tt.want; got != want {
+			t.Errorf("%s: got=%q, want=%q", tt.text, got, want)
+		}
+	}
+}
+
+func TestInterpolateDecrypted(t *testing.T) {
+	node, err := hcl.ParseString(`
+		password = "s3cret"
+		dsn = "postgres://u:${password}@h"
+		copy = "${dsn}"
+		hostname = "h"
+		url = "http://${hostname}"
+	`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file := &File{Contents: node}
+	literal := func(key string) *ast.LiteralType {
+		return literalAt(t, file, key)
+	}
+	decrypted := map[*ast.LiteralType]bool{literal("password"): true}
+	if err := interpolate(node, nil, decrypted); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]bool{"dsn": true, "copy": true, "url": false} {
+		if got := decrypted[literal(key)]; got != want {
+			t.Errorf("%s: got decrypted=%v, want %v", key, got, want)
+		}
+	}
+}
+
+func TestInterpolateDecryptedRedacted(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.hcl")
+	writeConfig(t, filename, encryptConfig(t, `
+		encryption {
+			test = true
+		}
+		password = "s3cret"
+		dsn = "postgres://u:${password}@h"
+	`), time.Now())
+
+	loader := &Loader{
+		KeyProviders: []KeyProvider{testKeyProvider},
+		Interpolate:  true,
+	}
+	file, err := loader.Get(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	DebugHandler(func() *File { return file }).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if strings.Contains(w.Body.String(), "s3cret") {
+		t.Errorf("debug output contains secret:\n%s", w.Body.String())
+	}
+	origin, err := file.Origin("dsn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !origin.Decrypted {
+		t.Error("dsn: got decrypted=false, want true")
+	}
+}
+
+// literalAt returns the literal value at path in the file.
+func literalAt(t *testing.T, f *File, path string) *ast.LiteralType {
+	t.Helper()
+	filter, err := f.lookupPath(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	items := filter.Elem().Items
+	if len(items) != 1 {
+		t.Fatalf("%s: got %d values, want 1", path, len(items))
+	}
+	lit, ok := items[0].Val.(*ast.LiteralType)
+	if !ok {
+		t.Fatalf("%s: got %T, want literal", path, items[0].Val)
+	}
+	return lit
+}
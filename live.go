@@ -0,0 +1,148 @@
+package hclconfig
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Live holds the current decoded value of a configuration file, and
+// replaces it when the file changes. The current value can be read
+// from any number of goroutines while a reload is in progress.
+//
+// If a new version of the file cannot be downloaded, parsed, decrypted,
+// decoded or validated, Live keeps the last good value.
+type Live[T any] struct {
+	value atomic.Pointer[T]
+
+	mu   sync.Mutex // serializes reloads
+	file *File      // most recently loaded version of the file
+
+	subscribersMu sync.Mutex
+	subscribers   []func(old, new *T)
+}
+
+// NewLive loads the configuration file at location using the loader,
+// and decodes it into a new value of type T. If loader is nil, the
+// default loader used by Get is used.
+//
+// Unlike reloads, an error loading or decoding the initial version of
+// the file is returned, as there is no previous good value to use.
+func NewLive[T any](ctx context.Context, loader *Loader, location string) (*Live[T], error) {
+	file, err := loaderOrDefault(loader).GetContext(ctx, location)
+	if err != nil {
+		return nil, err
+	}
+	value, err := decodeLive[T](file)
+	if err != nil {
+		return nil, err
+	}
+	l := &Live[T]{file: file}
+	l.value.Store(value)
+	return l, nil
+}
+
+// decodeLive decodes the file into a new value of type T.
+func decodeLive[T any](file *File) (*T, error) {
+	value := new(T)
+	if err := file.Decode(value); err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// Load returns the current value. The value must not be modified, as it
+// is shared with other goroutines.
+func (l *Live[T]) Load() *T {
+	return l.value.Load()
+}
+
+// File returns the most recently loaded version of the configuration
+// file. If the most recent version could not be decoded, this file does
+// not correspond to the value returned by Load.
+func (l *Live[T]) File() *File {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.file
+}
+
+// Subscribe adds a function that is called with the old and new values
+// each time the value is replaced. Subscribers are called in the order
+// they were added, from the goroutine that performed the reload, after
+// the value and file have been replaced. Subscribers can call Load and
+// File.
+func (l *Live[T]) Subscribe(fn func(old, new *T)) {
+	l.subscribersMu.Lock()
+	defer l.subscribersMu.Unlock()
+	l.subscribers = append(l.subscribers, fn)
+}
+
+// Reload checks whether the configuration file has changed, and if so
+// loads and decodes the new version. It returns true if the value was
+// replaced.
+//
+// If the new version cannot be loaded, decoded or validated, Reload
+// returns the error and the current value is kept. A version that
+// fails to decode is not retried until the file changes again.
+func (l *Live[T]) Reload(ctx context.Context) (bool, error) {
+	old, value, err := l.reload(ctx)
+	if err != nil || value == nil {
+		return false, err
+	}
+
+	// subscribers are called without holding the lock, so that
+	// they can call File
+	l.subscribersMu.Lock()
+	subscribers := l.subscribers
+	l.subscribersMu.Unlock()
+	for _, fn := range subscribers {
+		fn(old, value)
+	}
+	return true, nil
+}
+
+// reload replaces the file and value if the file has changed, and
+// returns the old and new values. The new value is nil if the value
+// was not replaced.
+func (l *Live[T]) reload(ctx context.Context) (old, value *T, err error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	file, changed, err := l.file.RefreshContext(ctx)
+	if err != nil || !changed {
+		return nil, nil, err
+	}
+	l.file = file
+	value, err = decodeLive[T](file)
+	if err != nil {
+		return nil, nil, err
+	}
+	return l.value.Swap(value), value, nil
+}
+
+// Watch calls Reload at the specified interval until the context is
+// cancelled. Errors are passed to onError, which can be nil, and do not
+// stop the polling. Watch blocks until the context is done, and then
+// returns the context's error.
+func (l *Live[T]) Watch(ctx context.Context, interval time.Duration, onError func(error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		_, err := l.Reload(ctx)
+		if ctx.Err() != nil {
+			// do not report a result after cancellation
+			return ctx.Err()
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package hclconfig
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLive(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.hcl")
+	modTime := time.Now().Add(-time.Hour)
+	writeConfig(t, filename, `port = 80`, modTime)
+
+	type config struct {
+		Port int `validate:"min=1,max=65535"`
+	}
+	ctx := context.Background()
+	live, err := NewLive[config](ctx, nil, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := live.Load().Port, 80; got != want {
+		t.Fatalf("got=%d, want=%d", got, want)
+	}
+
+	var calls [][2]int
+	live.Subscribe(func(old, new *config) {
+		calls = append(calls, [2]int{old.Port, new.Port})
+	})
+
+	// unchanged
+	if changed, err := live.Reload(ctx); err != nil || changed {
+		t.Fatalf("got changed=%v err=%v, want unchanged", changed, err)
+	}
+
+	// changed
+	writeConfig(t, filename, `port = 443`, modTime.Add(time.Minute))
+	if changed, err := live.Reload(ctx); err != nil || !changed {
+		t.Fatalf("got changed=%v err=%v, want changed", changed, err)
+	}
+	if got, want := live.Load().Port, 443; got != want {
+		t.Errorf("got=%d, want=%d", got, want)
+	}
+
+	// invalid versions keep the last good value
+	for i, text := range []string{`port = 70000`, `port = "unterminated`} {
+		writeConfig(t, filename, text, modTime.Add(time.Duration(i+2)*time.Minute))
+		if changed, err := live.Reload(ctx); err == nil || changed {
+			t.Errorf("%s: got changed=%v err=%v, want error", text, changed, err)
+		}
+		if got, want := live.Load().Port, 443; got != want {
+			t.Errorf("%s: got=%d, want=%d", text, got, want)
+		}
+	}
+
+	writeConfig(t, filename, `port = 8080`, modTime.Add(5*time.Minute))
+	if changed, err := live.Reload(ctx); err != nil || !changed {
+		t.Fatalf("got changed=%v err=%v, want changed", changed, err)
+	}
+
+	want := [][2]int{{80, 443}, {443, 8080}}
+	if len(calls) != len(want) || calls[0] != want[0] || calls[1] != want[1] {
+		t.Errorf("got=%v, want=%v", calls, want)
+	}
+
+	// an invalid initial version is an error
+	writeConfig(t, filename, `port = 0`, modTime)
+	if _, err := NewLive[config](ctx, nil, filename); err == nil {
+		t.Error("got nil, want error")
+	}
+}
+
+func TestLiveSubscriberCallsFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.hcl")
+	modTime := time.Now().Add(-time.Hour)
+	writeConfig(t, filename, `port = 80`, modTime)
+
+	type config struct {
+		Port int
+	}
+	ctx := context.Background()
+	live, err := NewLive[config](ctx, nil, filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var port int
+	live.Subscribe(func(old, new *config) {
+		port, _ = live.File().GetInt("port")
+	})
+
+	writeConfig(t, filename, `port = 443`, modTime.Add(time.Minute))
+	done := make(chan error, 1)
+	go func() {
+		_, err := live.Reload(ctx)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("deadlock: subscriber called File during Reload")
+	}
+	if got, want := port, 443; got != want {
+		t.Errorf("got=%d, want=%d", got, want)
+	}
+}
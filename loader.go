@@ -0,0 +1,313 @@
+package hclconfig
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/hclconfig/amzn"
+	"github.com/jjeffery/hclconfig/astcrypt"
+	"github.com/jjeffery/hclconfig/download"
+	"github.com/jjeffery/hclconfig/encryption"
+)
+
+var (
+	// defaultLoader is used by the package-level functions.
+	defaultLoader = &Loader{}
+)
+
+// KeyProvider provides the data encryption key used to decrypt
+// a configuration file.
+type KeyProvider interface {
+	// Key returns the data encryption key specified in the configuration
+	// file. If the configuration file does not contain key information
+	// that the provider understands, Key returns a nil key and a nil error.
+	Key(ctx context.Context, node ast.Node) (encryption.Key, error)
+}
+
+// KeyProviderFunc is an adapter that allows an ordinary function to
+// be used as a KeyProvider.
+type KeyProviderFunc func(ctx context.Context, node ast.Node) (encryption.Key, error)
+
+// Key calls f(ctx, node).
+func (f KeyProviderFunc) Key(ctx context.Context, node ast.Node) (encryption.Key, error) {
+	return f(ctx, node)
+}
+
+// Loader loads configuration files. Its fields control how configuration
+// files are downloaded and decrypted. A program can use more than one
+// loader, for example to load configuration files from different AWS
+// accounts. The zero value is ready to use, and behaves the same as
+// the package-level functions.
+//
+// A Loader should not be modified after it has been used.
+type Loader struct {
+	// HTTPClient is used to download HTTP and HTTPS locations. If nil,
+	// a client with a one minute timeout is used.
+	HTTPClient *http.Client
+
+	// HTTPOptions configures the headers, credentials and TLS settings
+	// of requests to HTTP and HTTPS locations. HTTPLocations configures
+	// them for locations with a specific prefix. See download.HTTPOptions.
+	HTTPOptions   *download.HTTPOptions
+	HTTPLocations map[string]*download.HTTPOptions
+
+	// AWSSession is used for S3, SSM and Secrets Manager locations and
+	// for AWS KMS. If nil, the session returned by amzn.AWSSession is used.
+	AWSSession *session.Session
+
+	// KeyProviders are asked in order for the data encryption key of each
+	// configuration file. The first non-nil key is used. If empty, the
+	// data key is decrypted using AWS KMS.
+	KeyProviders []KeyProvider
+
+	// Schemes lists the URL schemes that the loader is permitted to
+	// access, eg "https", "s3" or "file". Local file paths are treated
+	// as having the "file" scheme. If empty, all schemes registered with
+	// download.RegisterScheme are permitted.
+	Schemes []string
+
+	// Retry determines how downloads that fail with a transient error
+	// are retried. If nil, download.DefaultRetryPolicy is used.
+	Retry *download.RetryPolicy
+
+	// Interpolate enables the replacement of references in string
+	// values after decryption. References have the form ${env.NAME},
+	// ${env.NAME:-default} or ${path.to.key}, where the last form refers
+	// to another value in the same file. Use $${ for a literal ${.
+	// Any reference that cannot be resolved is an error.
+	Interpolate bool
+
+	// Environment selects the environment blocks that are merged over
+	// the top-level values of each configuration file, eg
+	//  env "production" {
+	//      database { hostname = "db.example.com" }
+	//  }
+	// If empty, the environment is taken from the HCLCONFIG_ENV
+	// environment variable. When an environment is selected, all of the
+	// environment blocks are removed from the file, so they are not
+	// decoded. When no environment is selected, the file is unchanged.
+	Environment string
+
+	// Observer, if not nil, is notified of downloads, key decryption,
+	// checks for changes and refreshes.
+	Observer Observer
+
+	// CacheDir, if not empty, is a directory where the loader keeps the
+	// last version of each file that it downloaded successfully. If a
+	// file cannot be downloaded, the cached version is used instead, and
+	// the File has FromCache set. Files are cached as downloaded, so
+	// encrypted values are never stored in clear text. SSM parameters and
+	// Secrets Manager secrets are decrypted by the server, so they are
+	// never cached.
+	CacheDir string
+}
+
+// Get downloads the configuration file from the location, parses it
+// and decrypts any sensitive data.
+func (l *Loader) Get(location string) (*File, error) {
+	return l.GetContext(context.Background(), location)
+}
+
+// GetContext is like Get, but cancelling the context aborts any
+// download or key decryption that is in progress.
+func (l *Loader) GetContext(ctx context.Context, location string) (*File, error) {
+	return l.GetWithFallbackContext(ctx, location)
+}
+
+// load parses and decrypts a file that has been downloaded from location,
+// or read from the cache directory if fromCache is true. Downloaded files,
+// including any included files, are stored in the cache directory once
+// they have been loaded successfully.
+func (l *Loader) load(ctx context.Context, location string, d *download.File, fromCache bool) (*File, error) {
+	node, err := hcl.ParseBytes(d.Body)
+	if err != nil {
+		return nil, errors.Wrap(err).With(
+			"location", download.RedactLocation(location),
+		)
+	}
+	setFilename(node, location)
+	decrypted := make(map[*ast.LiteralType]bool)
+	if d.Sensitive {
+		markDecrypted(node, decrypted)
+	}
+	inc := includer{loader: l, decrypted: decrypted}
+	if err := inc.resolve(ctx, node, location, nil); err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	key, err := l.key(ctx, node)
+	if l.Observer != nil && (key != nil || err != nil) {
+		l.Observer.KeyUnwrap(KeyUnwrapEvent{
+			Location: download.RedactLocation(location),
+			Duration: time.Since(start),
+			Err:      err,
+		})
+	}
+	if err != nil {
+		return nil, errors.Wrap(err).With(
+			"location", download.RedactLocation(location),
+		)
+	}
+	var decrypter astcrypt.Decrypter
+	if key != nil {
+		// avoid a non-nil interface containing a nil key
+		decrypter = key
+	}
+	err = astcrypt.DecryptFunc(node, decrypter, func(lit *ast.LiteralType) {
+		decrypted[lit] = true
+	})
+	if err != nil {
+		return nil, errors.Wrap(err).With(
+			"location", download.RedactLocation(location),
+		)
+	}
+	if l.Observer != nil {
+		l.Observer.Decrypt(DecryptEvent{
+			Location: download.RedactLocation(location),
+			Count:    len(decrypted),
+		})
+	}
+	selectEnvironment(node, l.environment())
+	if l.Interpolate {
+		if err := interpolate(node, nil, decrypted); err != nil {
+			return nil, errors.Wrap(err).With(
+				"location", download.RedactLocation(location),
+			)
+		}
+	}
+	f := &File{
+		Location:       location,
+		Etag:           d.ETag,
+		LastModified:   d.LastModified,
+		Contents:       node,
+		loader:         l,
+		includes:       inc.files,
+		decrypted:      decrypted,
+		fingerprintKey: newFingerprintKey(key),
+		FromCache:      fromCache || inc.fromCache,
+	}
+	f.refreshed.Store(time.Now().UnixNano())
+
+	// only cache files that have been loaded successfully
+	if !fromCache {
+		l.writeCache(d)
+	}
+	for _, d := range inc.downloaded {
+		l.writeCache(d)
+	}
+	return f, nil
+}
+
+// markDecrypted adds every literal value in the node to decrypted. It is
+// used for files whose body was decrypted by the server, such as SSM
+// SecureString parameters and Secrets Manager secrets, so that all of
+// their values are treated as secrets.
+func markDecrypted(node ast.Node, decrypted map[*ast.LiteralType]bool) {
+	ast.Walk(node, func(n ast.Node) (ast.Node, bool) {
+		if lit, ok := n.(*ast.LiteralType); ok {
+			decrypted[lit] = true
+		}
+		return n, true
+	})
+}
+
+// GetLayered loads the configuration file from each of the locations and
+// merges them into a single file. Later locations take precedence over
+// earlier locations. See GetLayered for details.
+func (l *Loader) GetLayered(locations ...string) (*File, error) {
+	return l.GetLayeredContext(context.Background(), locations...)
+}
+
+// GetLayeredContext is like GetLayered, but cancelling the context aborts
+// any download or key decryption that is in progress.
+func (l *Loader) GetLayeredContext(ctx context.Context, locations ...string) (*File, error) {
+	if len(locations) == 0 {
+		return nil, errors.New("no locations specified")
+	}
+	layers := make([]*File, 0, len(locations))
+	for _, location := range locations {
+		layer, err := l.GetContext(ctx, location)
+		if err != nil {
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+
+	return l.merge(layers), nil
+}
+
+// merge returns a file that contains the layers merged in order.
+func (l *Loader) merge(layers []*File) *File {
+	locations := make([]string, len(layers))
+	for i, layer := range layers {
+		locations[i] = layer.Location
+	}
+	f := &File{
+		Location:  strings.Join(locations, ","),
+		Contents:  layers[0].Contents,
+		Layers:    layers,
+		loader:    l,
+		decrypted: make(map[*ast.LiteralType]bool),
+	}
+	f.refreshed.Store(time.Now().UnixNano())
+	for _, layer := range layers[1:] {
+		f.Contents = mergeFiles(f.Contents, layer.Contents)
+	}
+	for _, layer := range layers {
+		if layer.LastModified.After(f.LastModified) {
+			f.LastModified = layer.LastModified
+		}
+		for lit := range layer.decrypted {
+			f.decrypted[lit] = true
+		}
+		f.FromCache = f.FromCache || layer.FromCache
+	}
+	return f
+}
+
+func (l *Loader) key(ctx context.Context, node ast.Node) (encryption.Key, error) {
+	providers := l.KeyProviders
+	if len(providers) == 0 {
+		providers = []KeyProvider{KeyProviderFunc(l.awsClient().NewKey)}
+	}
+	for _, provider := range providers {
+		key, err := provider.Key(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			return key, nil
+		}
+	}
+	return nil, nil
+}
+
+func (l *Loader) awsClient() *amzn.Client {
+	return &amzn.Client{Session: l.AWSSession}
+}
+
+func (l *Loader) downloader() *download.Downloader {
+	return &download.Downloader{
+		HTTPClient:    l.HTTPClient,
+		HTTPOptions:   l.HTTPOptions,
+		HTTPLocations: l.HTTPLocations,
+		AWS:           l.awsClient(),
+		Schemes:       l.Schemes,
+		Retry:         l.Retry,
+		Observer:      l.Observer,
+	}
+}
+
+// loaderOrDefault returns l, or the default loader if l is nil.
+func loaderOrDefault(l *Loader) *Loader {
+	if l == nil {
+		return defaultLoader
+	}
+	return l
+}
@@ -0,0 +1,218 @@
+package hclconfig
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/credentials"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/hashicorp/hcl"
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/printer"
+	"github.com/jjeffery/hclconfig/astcrypt"
+	"github.com/jjeffery/hclconfig/download"
+	"github.com/jjeffery/hclconfig/encryption"
+)
+
+var testKey = encryption.Key{
+	0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07,
+	0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+	0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17,
+	0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+}
+
+// testKeyProvider provides testKey for any configuration file
+// that contains an encryption block.
+var testKeyProvider = KeyProviderFunc(func(ctx context.Context, node ast.Node) (encryption.Key, error) {
+	var data struct {
+		Encryption map[string]interface{}
+	}
+	if err := hcl.DecodeObject(&data, node); err != nil {
+		return nil, err
+	}
+	if data.Encryption == nil {
+		return nil, nil
+	}
+	return testKey, nil
+})
+
+// encryptConfig encrypts any values whose keys contain "password"
+// using testKey, and returns the resulting HCL text.
+func encryptConfig(t *testing.T, text string) string {
+	t.Helper()
+	node, err := hcl.ParseString(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := astcrypt.Encrypt(node, testKey, []string{"password"}, nil); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := printer.Fprint(&buf, node); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestLoaderKeyProviders(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.hcl")
+	text := encryptConfig(t, `
+		encryption {
+			test = true
+		}
+		database {
+			password = "s3cret"
+		}
+	`)
+	if err := ioutil.WriteFile(filename, []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	loader := &Loader{
+		KeyProviders: []KeyProvider{testKeyProvider},
+	}
+	file, err := loader.Get(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var config struct {
+		Database struct {
+			Password string
+		}
+	}
+	if err := file.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Database.Password, "s3cret"; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+}
+
+func TestLoaderSchemes(t *testing.T) {
+	loader := &Loader{
+		Schemes: []string{"https"},
+	}
+	if _, err := loader.Get("testdata/example.hcl"); err == nil {
+		t.Error("got nil, want error")
+	}
+}
+
+type countingTransport struct {
+	count int
+}
+
+func (t *countingTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	t.count++
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+func TestLoaderRetry(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	loader := &Loader{
+		Retry: &download.RetryPolicy{MaxAttempts: 2, BaseDelay: time.Millisecond},
+	}
+	if _, err := loader.Get(server.URL); err == nil {
+		t.Fatal("got nil, want error")
+	}
+	if got, want := atomic.LoadInt32(&requests), int32(2); got != want {
+		t.Errorf("requests: got=%d, want=%d", got, want)
+	}
+}
+
+func TestLoaderHTTPClient(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Etag", `"1"`)
+		w.Write([]byte(`value = "one"`))
+	}))
+	defer server.Close()
+
+	transport := &countingTransport{}
+	loader := &Loader{
+		HTTPClient: &http.Client{Transport: transport},
+	}
+	file, err := loader.Get(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changed, err := file.HasChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("got changed, want unchanged")
+	}
+	if got, want := transport.count, 2; got != want {
+		t.Errorf("got=%d, want=%d", got, want)
+	}
+}
+
+func TestLoaderSecretsManager(t *testing.T) {
+	// a stub of the Secrets Manager API
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Amz-Target") != "secretsmanager.GetSecretValue" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.Write([]byte(`{"Name":"myapp/config","VersionId":"v1","CreatedDate":1500000000,` +
+			`"SecretString":"database {\n  password = \"s3cret\"\n}\n"}`))
+	}))
+	defer server.Close()
+	sess, err := session.NewSession(&aws.Config{
+		Endpoint:    aws.String(server.URL),
+		Region:      aws.String("us-east-1"),
+		Credentials: credentials.NewStaticCredentials("id", "secret", ""),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := (&Loader{AWSSession: sess}).Get("secretsmanager://myapp/config")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the secret was decrypted by the server, so every value is a secret
+	var config struct {
+		Database struct {
+			Password Secret
+		}
+	}
+	if err := file.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Database.Password.Reveal(), "s3cret"; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+
+	handler := DebugHandler(func() *File { return file })
+	for _, target := range []string{"/debug/config", "/debug/config?format=json"} {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		if body := w.Body.String(); strings.Contains(body, "s3cret") {
+			t.Errorf("%s: secret displayed:\n%s", target, body)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package hclconfig
+
+import (
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+)
+
+// mergeFiles returns a new file containing the items in base,
+// overridden by the items in over. Neither base nor over is modified.
+func mergeFiles(base, over *ast.File) *ast.File {
+	return &ast.File{
+		Node:     mergeLists(objectList(base.Node), objectList(over.Node)),
+		Comments: append(base.Comments[:len(base.Comments):len(base.Comments)], over.Comments...),
+	}
+}
+
+// mergeLists returns a new object list containing the items in base,
+// overridden by the items in over.
+//
+// Items are matched by their keys, compared case-insensitively as
+// written in the file. When base and over each contain exactly one
+// item for a key and both items are objects, the objects are merged
+// recursively. Otherwise the items in over replace all of the items
+// in base with the same key. This means that repeated blocks, which
+// HCL decodes as a list, are replaced as a whole.
+func mergeLists(base, over *ast.ObjectList) *ast.ObjectList {
+	baseItems := groupItems(base)
+	overItems := groupItems(over)
+
+	result := &ast.ObjectList{}
+	done := make(map[string]bool)
+
+	add := func(key string) {
+		if done[key] {
+			return
+		}
+		done[key] = true
+		b, o := baseItems[key], overItems[key]
+		switch {
+		case len(o) == 0:
+			result.Items = append(result.Items, b...)
+		case len(b) == 1 && len(o) == 1:
+			result.Items = append(result.Items, mergeItems(b[0], o[0]))
+		default:
+			result.Items = append(result.Items, o...)
+		}
+	}
+
+	// preserve the order of base, with any new keys at the end
+	for _, item := range base.Items {
+		add(itemKey(item))
+	}
+	for _, item := range over.Items {
+		add(itemKey(item))
+	}
+
+	return result
+}
+
+// mergeItems merges two items with the same key. If both are objects,
+// the result is an object containing the merged contents. Otherwise
+// the result is over.
+func mergeItems(base, over *ast.ObjectItem) *ast.ObjectItem {
+	baseObj, ok := base.Val.(*ast.ObjectType)
+	if !ok {
+		return over
+	}
+	overObj, ok := over.Val.(*ast.ObjectType)
+	if !ok {
+		return over
+	}
+	item := *over
+	item.Val = &ast.ObjectType{
+		Lbrace: overObj.Lbrace,
+		Rbrace: overObj.Rbrace,
+		List:   mergeLists(baseObj.List, overObj.List),
+	}
+	return &item
+}
+
+// groupItems returns the items in the list, grouped by key.
+func groupItems(list *ast.ObjectList) map[string][]*ast.ObjectItem {
+	m := make(map[string][]*ast.ObjectItem)
+	for _, item := range list.Items {
+		key := itemKey(item)
+		m[key] = append(m[key], item)
+	}
+	return m
+}
+
+// itemKey returns a string that identifies all of the keys of an item.
+func itemKey(item *ast.ObjectItem) string {
+	keys := make([]string, len(item.Keys))
+	for i, key := range item.Keys {
+		keys[i] = strings.ToLower(keyText(key))
+	}
+	return strings.Join(keys, "\x00")
+}
+
+// keyText returns the text of the key, without any quotes.
+func keyText(key *ast.ObjectKey) string {
+	if s, ok := key.Token.Value().(string); ok {
+		return s
+	}
+	return key.Token.Text
+}
+
+// objectList returns the object list for a node, which should be
+// either an *ast.ObjectList or an *ast.ObjectType. It returns an
+// empty list for any other node type.
+func objectList(node ast.Node) *ast.ObjectList {
+	switch n := node.(type) {
+	case *ast.ObjectList:
+		return n
+	case *ast.ObjectType:
+		return n.List
+	}
+	return &ast.ObjectList{}
+}
@@ -0,0 +1,124 @@
+package hclconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestGetLayered(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	modTime := time.Now().Add(-time.Hour)
+	base := filepath.Join(dir, "base.hcl")
+	writeConfig(t, base, encryptConfig(t, `
+		encryption {
+			test = true
+		}
+		database {
+			hostname = "db.example.com"
+			port = 5432
+			password = "s3cret"
+		}
+		listener {
+			port = 80
+		}
+		listener {
+			port = 443
+		}
+		service "web" {
+			replicas = 2
+			image = "web:1"
+		}
+		service "worker" {
+			replicas = 1
+		}
+	`), modTime)
+	override := filepath.Join(dir, "override.hcl")
+	writeConfig(t, override, `
+		database {
+			hostname = "db.test.example.com"
+		}
+		listener {
+			port = 8080
+		}
+		service "web" {
+			replicas = 5
+		}
+		debug = true
+	`, modTime)
+
+	loader := &Loader{
+		KeyProviders: []KeyProvider{testKeyProvider},
+	}
+	file, err := loader.GetLayered(base, override)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type service struct {
+		Replicas int
+		Image    string
+	}
+	var config struct {
+		Database struct {
+			Hostname string
+			Port     int
+			Password string
+		}
+		Listener []struct {
+			Port int
+		}
+		Service map[string]service
+		Debug   bool
+	}
+	if err := file.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := config.Database.Hostname, "db.test.example.com"; got != want {
+		t.Errorf("hostname: got=%q, want=%q", got, want)
+	}
+	if got, want := config.Database.Port, 5432; got != want {
+		t.Errorf("port: got=%d, want=%d", got, want)
+	}
+	if got, want := config.Database.Password, "s3cret"; got != want {
+		t.Errorf("password: got=%q, want=%q", got, want)
+	}
+	if got, want := len(config.Listener), 1; got != want {
+		t.Errorf("listeners: got=%d, want=%d", got, want)
+	}
+	wantServices := map[string]service{
+		"web":    {Replicas: 5, Image: "web:1"},
+		"worker": {Replicas: 1},
+	}
+	if got, want := config.Service, wantServices; !reflect.DeepEqual(got, want) {
+		t.Errorf("services: got=%+v, want=%+v", got, want)
+	}
+	if !config.Debug {
+		t.Error("debug: got=false, want=true")
+	}
+
+	changed, err := file.HasChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changed {
+		t.Error("got changed, want unchanged")
+	}
+	writeConfig(t, override, `debug = false`, modTime.Add(time.Minute))
+	changed, err = file.HasChanged()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("got unchanged, want changed")
+	}
+}
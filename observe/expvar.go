@@ -0,0 +1,84 @@
+package observe
+
+import (
+	"expvar"
+
+	"github.com/jjeffery/hclconfig"
+	"github.com/jjeffery/hclconfig/download"
+)
+
+// Expvar is an observer that maintains counters in an expvar.Map.
+// The following counters are maintained:
+//  fetches                 number of downloads, by scheme and status,
+//                          eg "fetches.https.ok"
+//  fetch_bytes             total bytes downloaded
+//  fetch_seconds           total time spent downloading
+//  change_checks           number of checks for changes
+//  change_check_errors     number of checks that failed
+//  changes_detected        number of checks that found a change
+//  key_unwraps             number of data keys decrypted
+//  key_unwrap_errors       number of data keys that could not be decrypted
+//  key_unwrap_seconds      total time spent decrypting data keys
+//  decrypted_values        number of values decrypted
+//  reloads                 number of refreshes
+//  reload_errors           number of refreshes that failed
+//  reloads_changed         number of refreshes that loaded a new version
+type Expvar struct {
+	m *expvar.Map
+}
+
+// NewExpvar returns an observer that maintains counters in m. If m is
+// nil, a map named "hclconfig" is created and published. As with
+// expvar.NewMap, this panics if the name is already in use.
+func NewExpvar(m *expvar.Map) *Expvar {
+	if m == nil {
+		m = expvar.NewMap("hclconfig")
+	}
+	return &Expvar{m: m}
+}
+
+// FetchStart implements download.Observer.
+func (o *Expvar) FetchStart(e download.FetchStartEvent) {}
+
+// FetchDone implements download.Observer.
+func (o *Expvar) FetchDone(e download.FetchEvent) {
+	o.m.Add("fetches."+e.Scheme+"."+e.Status, 1)
+	o.m.Add("fetch_bytes", int64(e.Bytes))
+	o.m.AddFloat("fetch_seconds", e.Duration.Seconds())
+}
+
+// ChangeCheck implements hclconfig.Observer.
+func (o *Expvar) ChangeCheck(e hclconfig.ChangeCheckEvent) {
+	o.m.Add("change_checks", 1)
+	if e.Err != nil {
+		o.m.Add("change_check_errors", 1)
+	}
+	if e.Changed {
+		o.m.Add("changes_detected", 1)
+	}
+}
+
+// KeyUnwrap implements hclconfig.Observer.
+func (o *Expvar) KeyUnwrap(e hclconfig.KeyUnwrapEvent) {
+	o.m.Add("key_unwraps", 1)
+	if e.Err != nil {
+		o.m.Add("key_unwrap_errors", 1)
+	}
+	o.m.AddFloat("key_unwrap_seconds", e.Duration.Seconds())
+}
+
+// Decrypt implements hclconfig.Observer.
+func (o *Expvar) Decrypt(e hclconfig.DecryptEvent) {
+	o.m.Add("decrypted_values", int64(e.Count))
+}
+
+// Reload implements hclconfig.Observer.
+func (o *Expvar) Reload(e hclconfig.ReloadEvent) {
+	o.m.Add("reloads", 1)
+	if e.Err != nil {
+		o.m.Add("reload_errors", 1)
+	}
+	if e.Changed {
+		o.m.Add("reloads_changed", 1)
+	}
+}
@@ -0,0 +1,17 @@
+// Package observe provides implementations of hclconfig.Observer that
+// publish metrics using package expvar, and that log using package
+// log/slog.
+//
+// The expvar metrics are plain counters, so they can be scraped by any
+// system that reads expvar, including Prometheus exporters.
+package observe
+
+import (
+	"github.com/jjeffery/hclconfig"
+)
+
+// check that the observers implement the interface
+var (
+	_ hclconfig.Observer = (*Expvar)(nil)
+	_ hclconfig.Observer = (*Slog)(nil)
+)
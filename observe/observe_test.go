@@ -0,0 +1,66 @@
+package observe
+
+import (
+	"bytes"
+	"errors"
+	"expvar"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jjeffery/hclconfig"
+	"github.com/jjeffery/hclconfig/download"
+)
+
+func TestExpvar(t *testing.T) {
+	m := new(expvar.Map).Init()
+	o := NewExpvar(m)
+	o.FetchDone(download.FetchEvent{Scheme: "https", Status: download.StatusOK, Bytes: 100, Duration: time.Second})
+	o.FetchDone(download.FetchEvent{Scheme: "https", Status: download.StatusOK, Bytes: 50})
+	o.FetchDone(download.FetchEvent{Scheme: "s3", Status: download.StatusError, Err: errors.New("failed")})
+	o.Decrypt(hclconfig.DecryptEvent{Count: 3})
+	o.Reload(hclconfig.ReloadEvent{Changed: true})
+	o.Reload(hclconfig.ReloadEvent{Err: errors.New("failed")})
+
+	tests := map[string]string{
+		"fetches.https.ok": "2",
+		"fetches.s3.error": "1",
+		"fetch_bytes":      "150",
+		"fetch_seconds":    "1",
+		"decrypted_values": "3",
+		"reloads":          "2",
+		"reloads_changed":  "1",
+		"reload_errors":    "1",
+	}
+	for key, want := range tests {
+		v := m.Get(key)
+		if v == nil {
+			t.Errorf("%s: missing", key)
+			continue
+		}
+		if got := v.String(); got != want {
+			t.Errorf("%s: got=%s, want=%s", key, got, want)
+		}
+	}
+}
+
+func TestSlog(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&buf, nil))
+	o := NewSlog(logger)
+	o.FetchStart(download.FetchStartEvent{Location: "https://example.com/config.hcl"})
+	o.Reload(hclconfig.ReloadEvent{Location: "config.hcl", Changed: true})
+	o.Reload(hclconfig.ReloadEvent{Location: "config.hcl", Err: errors.New("failed")})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if got, want := len(lines), 2; got != want {
+		t.Fatalf("got=%d, want=%d: %s", got, want, buf.String())
+	}
+	if !strings.Contains(lines[0], "level=INFO") || !strings.Contains(lines[0], "changed=true") {
+		t.Errorf("got=%s", lines[0])
+	}
+	if !strings.Contains(lines[1], "level=WARN") || !strings.Contains(lines[1], "error=failed") {
+		t.Errorf("got=%s", lines[1])
+	}
+}
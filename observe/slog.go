@@ -0,0 +1,93 @@
+package observe
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/jjeffery/hclconfig"
+	"github.com/jjeffery/hclconfig/download"
+)
+
+// Slog is an observer that logs events using a slog.Logger. Failures
+// are logged at warning level, new versions of files at info level,
+// and all other events at debug level.
+type Slog struct {
+	logger *slog.Logger
+}
+
+// NewSlog returns an observer that logs to logger. If logger is nil,
+// the default logger is used.
+func NewSlog(logger *slog.Logger) *Slog {
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return &Slog{logger: logger}
+}
+
+func (o *Slog) log(level slog.Level, msg string, err error, attrs ...slog.Attr) {
+	if err != nil {
+		level = slog.LevelWarn
+		attrs = append(attrs, slog.String("error", err.Error()))
+	}
+	o.logger.LogAttrs(context.Background(), level, msg, attrs...)
+}
+
+// FetchStart implements download.Observer.
+func (o *Slog) FetchStart(e download.FetchStartEvent) {
+	o.log(slog.LevelDebug, "hclconfig fetch start", nil,
+		slog.String("location", e.Location),
+		slog.String("scheme", e.Scheme),
+		slog.String("method", e.Method),
+	)
+}
+
+// FetchDone implements download.Observer.
+func (o *Slog) FetchDone(e download.FetchEvent) {
+	o.log(slog.LevelDebug, "hclconfig fetch", e.Err,
+		slog.String("location", e.Location),
+		slog.String("scheme", e.Scheme),
+		slog.String("method", e.Method),
+		slog.String("status", e.Status),
+		slog.Int("bytes", e.Bytes),
+		slog.Int("attempts", e.Attempts),
+		slog.Duration("duration", e.Duration),
+	)
+}
+
+// ChangeCheck implements hclconfig.Observer.
+func (o *Slog) ChangeCheck(e hclconfig.ChangeCheckEvent) {
+	o.log(slog.LevelDebug, "hclconfig change check", e.Err,
+		slog.String("location", e.Location),
+		slog.Bool("changed", e.Changed),
+		slog.Duration("duration", e.Duration),
+	)
+}
+
+// KeyUnwrap implements hclconfig.Observer.
+func (o *Slog) KeyUnwrap(e hclconfig.KeyUnwrapEvent) {
+	o.log(slog.LevelDebug, "hclconfig key unwrap", e.Err,
+		slog.String("location", e.Location),
+		slog.Duration("duration", e.Duration),
+	)
+}
+
+// Decrypt implements hclconfig.Observer.
+func (o *Slog) Decrypt(e hclconfig.DecryptEvent) {
+	o.log(slog.LevelDebug, "hclconfig decrypt", nil,
+		slog.String("location", e.Location),
+		slog.Int("count", e.Count),
+	)
+}
+
+// Reload implements hclconfig.Observer.
+func (o *Slog) Reload(e hclconfig.ReloadEvent) {
+	level := slog.LevelDebug
+	if e.Changed {
+		level = slog.LevelInfo
+	}
+	o.log(level, "hclconfig reload", e.Err,
+		slog.String("location", e.Location),
+		slog.Bool("changed", e.Changed),
+		slog.Duration("duration", e.Duration),
+	)
+}
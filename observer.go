@@ -0,0 +1,60 @@
+package hclconfig
+
+import (
+	"time"
+
+	"github.com/jjeffery/hclconfig/download"
+)
+
+// Observer is notified of the work done by a Loader and by the files it
+// loads, including every download. It can be used to collect metrics or
+// to log activity. Implementations must be safe for concurrent use.
+//
+// Package observe provides observers that publish metrics using expvar
+// and that log using log/slog.
+type Observer interface {
+	download.Observer
+
+	// ChangeCheck is called when File.HasChanged has finished.
+	ChangeCheck(e ChangeCheckEvent)
+
+	// KeyUnwrap is called when the data encryption key of a file
+	// has been decrypted, eg using AWS KMS.
+	KeyUnwrap(e KeyUnwrapEvent)
+
+	// Decrypt is called when the encrypted values in a file
+	// have been decrypted.
+	Decrypt(e DecryptEvent)
+
+	// Reload is called when File.Refresh has finished.
+	Reload(e ReloadEvent)
+}
+
+// ChangeCheckEvent describes a check for changes to a file.
+type ChangeCheckEvent struct {
+	Location string        // location of the file
+	Changed  bool          // true if the file has changed
+	Duration time.Duration // time taken by the check
+	Err      error         // error, if the check failed
+}
+
+// KeyUnwrapEvent describes the decryption of a data encryption key.
+type KeyUnwrapEvent struct {
+	Location string        // location of the file
+	Duration time.Duration // time taken to obtain the key
+	Err      error         // error, if the key could not be obtained
+}
+
+// DecryptEvent describes the decryption of the values in a file.
+type DecryptEvent struct {
+	Location string // location of the file
+	Count    int    // number of values decrypted
+}
+
+// ReloadEvent describes a refresh of a file.
+type ReloadEvent struct {
+	Location string        // location of the file
+	Changed  bool          // true if a new version was loaded
+	Duration time.Duration // time taken by the refresh
+	Err      error         // error, if the refresh failed
+}
@@ -0,0 +1,100 @@
+package hclconfig
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jjeffery/hclconfig/download"
+)
+
+// recordingObserver records a description of each event.
+type recordingObserver struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (o *recordingObserver) record(format string, args ...interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.events = append(o.events, fmt.Sprintf(format, args...))
+}
+
+func (o *recordingObserver) FetchStart(e download.FetchStartEvent) {
+	o.record("fetch start %s %s", e.Scheme, e.Method)
+}
+
+func (o *recordingObserver) FetchDone(e download.FetchEvent) {
+	o.record("fetch %s %s %s %v", e.Scheme, e.Method, e.Status, e.Bytes > 0)
+}
+
+func (o *recordingObserver) ChangeCheck(e ChangeCheckEvent) {
+	o.record("change check %v %v", e.Changed, e.Err)
+}
+
+func (o *recordingObserver) KeyUnwrap(e KeyUnwrapEvent) {
+	o.record("key unwrap %v", e.Err)
+}
+
+func (o *recordingObserver) Decrypt(e DecryptEvent) {
+	o.record("decrypt %d", e.Count)
+}
+
+func (o *recordingObserver) Reload(e ReloadEvent) {
+	o.record("reload %v %v", e.Changed, e.Err)
+}
+
+func TestLoaderObserver(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.hcl")
+	writeConfig(t, filename, encryptConfig(t, `
+		encryption {
+			test = true
+		}
+		database {
+			password = "s3cret"
+		}
+	`), time.Now().Add(-time.Hour))
+
+	observer := &recordingObserver{}
+	loader := &Loader{
+		KeyProviders: []KeyProvider{testKeyProvider},
+		Observer:     observer,
+	}
+	file, err := loader.Get(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := file.HasChanged(); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := file.Refresh(); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"fetch start file GET",
+		"fetch file GET ok true",
+		"key unwrap <nil>",
+		"decrypt 1",
+		"fetch start file HEAD",
+		"fetch file HEAD ok false",
+		"change check false <nil>",
+		"fetch start file GET",
+		"fetch file GET not-modified false",
+		"reload false <nil>",
+	}
+	if !reflect.DeepEqual(observer.events, want) {
+		t.Errorf("got=%q\nwant=%q", observer.events, want)
+	}
+}
@@ -0,0 +1,158 @@
+package hclconfig
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/jjeffery/hclconfig/download"
+)
+
+// Origin describes where a value in a configuration file came from.
+// This is useful when a file is merged from layers, includes and
+// environment blocks.
+type Origin struct {
+	Path      string // path of the value, eg "database.hostname"
+	Location  string // location of the file that contains the value
+	Line      int    // line number, starting at 1
+	Column    int    // column number, starting at 1
+	Decrypted bool   // true if the value was encrypted in the file
+}
+
+// String returns a description of the origin, eg "config.hcl:3:14".
+func (o *Origin) String() string {
+	s := fmt.Sprintf("%s:%d:%d", o.Location, o.Line, o.Column)
+	if o.Decrypted {
+		s += " (decrypted)"
+	}
+	return s
+}
+
+// Origin returns the origin of the value at path. See DecodePath for
+// the syntax of paths. If there is more than one value at path, such
+// as a repeated block, the origin of the last value is returned.
+func (f *File) Origin(path string) (*Origin, error) {
+	filter, err := f.lookupPath(path)
+	if err != nil {
+		return nil, err
+	}
+	item := filter.Items[0]
+	if elems := filter.Elem().Items; len(elems) > 0 {
+		item = elems[len(elems)-1]
+	}
+	return f.origin(path, item.Val), nil
+}
+
+// Origins returns the origin of every leaf value in the file, in the
+// order that they appear in the merged contents. Each element of a
+// list is a separate leaf.
+func (f *File) Origins() []*Origin {
+	var origins []*Origin
+	walkLeaves("", objectList(f.Contents.Node), func(path string, node ast.Node) {
+		origins = append(origins, f.origin(path, node))
+	})
+	return origins
+}
+
+func (f *File) origin(path string, node ast.Node) *Origin {
+	pos := nodePos(node)
+	o := &Origin{
+		Path:     path,
+		Location: pos.Filename,
+		Line:     pos.Line,
+		Column:   pos.Column,
+	}
+	if o.Location == "" {
+		o.Location = download.RedactLocation(f.Location)
+	}
+	if lit, ok := node.(*ast.LiteralType); ok {
+		o.Decrypted = f.decrypted[lit]
+	}
+	return o
+}
+
+// walkLeaves calls fn for every literal value in the list, and for every
+// empty object or list, with the path of the value.
+func walkLeaves(path string, list *ast.ObjectList, fn func(path string, node ast.Node)) {
+	done := make(map[string]bool)
+	for _, item := range list.Items {
+		if len(item.Keys) == 0 {
+			continue
+		}
+		key := keyText(item.Keys[0])
+		if done[strings.ToLower(key)] {
+			continue
+		}
+		done[strings.ToLower(key)] = true
+
+		filter := list.Filter(key)
+		keyPath := joinPath(path, quoteKey(key))
+		if children := filter.Children(); len(children.Items) > 0 {
+			walkLeaves(keyPath, children, fn)
+		}
+		elems := filter.Elem().Items
+		for i, elem := range elems {
+			elemPath := keyPath
+			if len(elems) > 1 {
+				elemPath = fmt.Sprintf("%s[%d]", keyPath, i)
+			}
+			walkValue(elemPath, elem.Val, fn)
+		}
+	}
+}
+
+func walkValue(path string, node ast.Node, fn func(path string, node ast.Node)) {
+	switch n := node.(type) {
+	case *ast.ObjectType:
+		if len(n.List.Items) == 0 {
+			fn(path, n)
+			return
+		}
+		walkLeaves(path, n.List, fn)
+	case *ast.ListType:
+		if len(n.List) == 0 {
+			fn(path, n)
+			return
+		}
+		for i, elem := range n.List {
+			walkValue(fmt.Sprintf("%s[%d]", path, i), elem, fn)
+		}
+	default:
+		fn(path, n)
+	}
+}
+
+// quoteKey returns the key as it is written in a path, quoting it if
+// it contains characters that have a special meaning in a path.
+func quoteKey(key string) string {
+	if key == "" || strings.ContainsAny(key, ".[]\" \t") {
+		return strconv.Quote(key)
+	}
+	return key
+}
+
+// setFilename records the location that a file was loaded from in the
+// position of every token in the node, so that the origin of values is
+// known after files are merged. Any credentials in the location are
+// redacted.
+func setFilename(node ast.Node, location string) {
+	location = download.RedactLocation(location)
+	ast.Walk(node, func(n ast.Node) (ast.Node, bool) {
+		switch n := n.(type) {
+		case *ast.ObjectItem:
+			n.Assign.Filename = location
+		case *ast.ObjectKey:
+			n.Token.Pos.Filename = location
+		case *ast.LiteralType:
+			n.Token.Pos.Filename = location
+		case *ast.ObjectType:
+			n.Lbrace.Filename = location
+			n.Rbrace.Filename = location
+		case *ast.ListType:
+			n.Lbrack.Filename = location
+			n.Rbrack.Filename = location
+		}
+		return n, true
+	})
+}
@@ -0,0 +1,94 @@
+package hclconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestOrigin(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	modTime := time.Now().Add(-time.Hour)
+	common := filepath.Join(dir, "common.hcl")
+	writeConfig(t, common, encryptConfig(t, `
+encryption {
+	test = true
+}
+database {
+	hostname = "localhost"
+	password = "s3cret"
+}
+`), modTime)
+	main := filepath.Join(dir, "main.hcl")
+	writeConfig(t, main, `include = "common.hcl"
+servers = ["a", "b"]
+env "production" {
+	database {
+		hostname = "db.example.com"
+	}
+}
+`, modTime)
+
+	loader := &Loader{
+		KeyProviders: []KeyProvider{testKeyProvider},
+		Environment:  "production",
+	}
+	file, err := loader.Get(main)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path      string
+		location  string
+		line      int
+		decrypted bool
+	}{
+		{path: "database.hostname", location: main, line: 5},
+		{path: "database.password", location: common, decrypted: true},
+		{path: "servers[1]", location: main, line: 2},
+	}
+	for _, tt := range tests {
+		origin, err := file.Origin(tt.path)
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		// the line of an encrypted value depends on the encrypted text
+		if tt.decrypted {
+			tt.line = origin.Line
+		}
+		if origin.Location != tt.location || origin.Line != tt.line || origin.Decrypted != tt.decrypted {
+			t.Errorf("%s: got=%v", tt.path, origin)
+		}
+	}
+	if _, err := file.Origin("database.port"); err == nil {
+		t.Error("got nil, want error")
+	}
+
+	var paths []string
+	for _, origin := range file.Origins() {
+		paths = append(paths, origin.Path)
+		if _, err := file.Origin(origin.Path); err != nil {
+			t.Errorf("%s: %v", origin.Path, err)
+		}
+	}
+	want := []string{
+		"encryption.test",
+		"database.hostname",
+		"database.password",
+		"servers[0]",
+		"servers[1]",
+	}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("got=%q, want=%q", paths, want)
+	}
+}
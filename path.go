@@ -0,0 +1,229 @@
+package hclconfig
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/jjeffery/errors"
+	"github.com/jjeffery/hclconfig/download"
+)
+
+// Has reports whether the configuration file contains a value at path.
+// See DecodePath for the syntax of paths.
+func (f *File) Has(path string) bool {
+	_, err := f.lookupPath(path)
+	return err == nil
+}
+
+// GetString returns the string value at path.
+func (f *File) GetString(path string) (string, error) {
+	var v string
+	err := f.DecodePath(path, &v)
+	return v, err
+}
+
+// GetInt returns the integer value at path.
+func (f *File) GetInt(path string) (int, error) {
+	var v int
+	err := f.DecodePath(path, &v)
+	return v, err
+}
+
+// GetBool returns the boolean value at path.
+func (f *File) GetBool(path string) (bool, error) {
+	var v bool
+	err := f.DecodePath(path, &v)
+	return v, err
+}
+
+// GetDuration returns the duration at path, which is decoded from
+// a string such as "30s".
+func (f *File) GetDuration(path string) (time.Duration, error) {
+	var v time.Duration
+	err := f.DecodePath(path, &v)
+	return v, err
+}
+
+// GetStringSlice returns the list of strings at path. A single string
+// value is returned as a slice of length one.
+func (f *File) GetStringSlice(path string) ([]string, error) {
+	var v []string
+	err := f.DecodePath(path, &v)
+	return v, err
+}
+
+// DecodePath decodes the value at path into the value pointed to by v,
+// using the same rules as Decode.
+//
+// A path is a list of keys separated by dots, eg "database.password".
+// Keys are matched case-insensitively. A key containing dots or other
+// special characters can be written in double quotes, and an element of
+// a list, or of a repeated block, is selected using an index:
+//  service."web.api".port
+//  servers[0].hostname
+// Block labels are keys, so that
+//  service "web" { port = 80 }
+// has the path "service.web.port".
+//
+// If there is no value at path, DecodePath returns an error that
+// names the path.
+func (f *File) DecodePath(path string, v interface{}) error {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return errors.New("result must be a non-nil pointer").With(
+			"location", download.RedactLocation(f.Location),
+		)
+	}
+	filter, err := f.lookupPath(path)
+	if err != nil {
+		return err
+	}
+
+	d := decoder{decrypted: f.decrypted}
+	d.decodeFilter(path, filter, val.Elem())
+	if len(d.errs) > 0 {
+		d.errs.setDefaultLocation(f.Location)
+		return d.errs
+	}
+	return nil
+}
+
+// lookupPath returns the items at path, in the same form as returned
+// by ast.ObjectList.Filter: items with keys remaining are prefix matches,
+// and items without keys are values.
+func (f *File) lookupPath(path string) (*ast.ObjectList, error) {
+	elems, err := parsePath(path)
+	if err != nil {
+		return nil, errors.Wrap(err).With(
+			"path", path,
+		)
+	}
+	notFound := func() error {
+		return errors.New("path not found").With(
+			"path", path,
+			"location", download.RedactLocation(f.Location),
+		)
+	}
+
+	root, ok := f.Contents.Node.(*ast.ObjectList)
+	if !ok {
+		return nil, notFound()
+	}
+	current := &ast.ObjectList{
+		Items: []*ast.ObjectItem{
+			{Val: &ast.ObjectType{List: root}},
+		},
+	}
+
+	for _, elem := range elems {
+		next := &ast.ObjectList{}
+		if elem.isIndex {
+			items := current.Elem().Items
+			if len(items) == 1 {
+				if list, ok := items[0].Val.(*ast.ListType); ok {
+					// index into a list value
+					items = nil
+					for _, node := range list.List {
+						items = append(items, &ast.ObjectItem{Val: node})
+					}
+				}
+			}
+			if elem.index < len(items) {
+				next.Add(items[elem.index])
+			}
+		} else {
+			for _, item := range current.Items {
+				if len(item.Keys) > 0 {
+					// prefix match, eg service "web" {...}
+					if strings.EqualFold(keyText(item.Keys[0]), elem.key) {
+						match := *item
+						match.Keys = match.Keys[1:]
+						next.Add(&match)
+					}
+					continue
+				}
+				if obj, ok := item.Val.(*ast.ObjectType); ok {
+					next.Items = append(next.Items, obj.List.Filter(elem.key).Items...)
+				}
+			}
+		}
+		if len(next.Items) == 0 {
+			return nil, notFound()
+		}
+		current = next
+	}
+	return current, nil
+}
+
+// pathElem is a single element of a path, which is either a key
+// or an index.
+type pathElem struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+// parsePath splits a path into its keys and indexes.
+func parsePath(path string) ([]pathElem, error) {
+	var elems []pathElem
+	s := path
+	expectKey := true
+	for len(s) > 0 {
+		switch {
+		case s[0] == '[':
+			i := strings.IndexByte(s, ']')
+			if i < 0 {
+				return nil, errors.New("missing ] in path")
+			}
+			index, err := strconv.Atoi(s[1:i])
+			if err != nil || index < 0 {
+				return nil, errors.New("invalid index in path")
+			}
+			elems = append(elems, pathElem{index: index, isIndex: true})
+			s = s[i+1:]
+			expectKey = false
+			continue
+		case s[0] == '.' && !expectKey:
+			s = s[1:]
+			expectKey = true
+			continue
+		case !expectKey:
+			return nil, errors.New("expected . or [ in path")
+		case s[0] == '"':
+			i := 1
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' {
+					i++
+				}
+				i++
+			}
+			if i >= len(s) {
+				return nil, errors.New("missing closing quote in path")
+			}
+			key, err := strconv.Unquote(s[:i+1])
+			if err != nil {
+				return nil, errors.New("invalid quoted key in path")
+			}
+			elems = append(elems, pathElem{key: key})
+			s = s[i+1:]
+		default:
+			i := strings.IndexAny(s, `.["`)
+			if i < 0 {
+				i = len(s)
+			}
+			if i == 0 {
+				return nil, errors.New("empty key in path")
+			}
+			elems = append(elems, pathElem{key: s[:i]})
+			s = s[i:]
+		}
+		expectKey = false
+	}
+	if len(elems) == 0 || expectKey {
+		return nil, errors.New("empty key in path")
+	}
+	return elems, nil
+}
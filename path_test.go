@@ -0,0 +1,132 @@
+package hclconfig
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestPathAccessors(t *testing.T) {
+	file := newTestFile(t, `
+database {
+	hostname = "db.example.com"
+	port = 5432
+	timeout = "30s"
+	readonly = true
+}
+service "web" {
+	port = 80
+}
+"web.api" {
+	port = 8080
+}
+servers = ["a", "b", "c"]
+listener {
+	port = 80
+}
+listener {
+	port = 443
+}
+oauth2 {
+	google {
+		client_id = "id"
+		scopes = ["email", "profile"]
+	}
+}
+`)
+
+	if got, err := file.GetString("database.hostname"); err != nil || got != "db.example.com" {
+		t.Errorf("GetString: got=%q, err=%v", got, err)
+	}
+	if got, err := file.GetInt("Database.Port"); err != nil || got != 5432 {
+		t.Errorf("GetInt: got=%d, err=%v", got, err)
+	}
+	if got, err := file.GetBool("database.readonly"); err != nil || !got {
+		t.Errorf("GetBool: got=%v, err=%v", got, err)
+	}
+	if got, err := file.GetDuration("database.timeout"); err != nil || got != 30*time.Second {
+		t.Errorf("GetDuration: got=%v, err=%v", got, err)
+	}
+	if got, err := file.GetInt("service.web.port"); err != nil || got != 80 {
+		t.Errorf("block label: got=%d, err=%v", got, err)
+	}
+	if got, err := file.GetInt(`"web.api".port`); err != nil || got != 8080 {
+		t.Errorf("quoted key: got=%d, err=%v", got, err)
+	}
+	if got, err := file.GetString("servers[1]"); err != nil || got != "b" {
+		t.Errorf("list index: got=%q, err=%v", got, err)
+	}
+	if got, err := file.GetInt("listener[1].port"); err != nil || got != 443 {
+		t.Errorf("block index: got=%d, err=%v", got, err)
+	}
+	if got, err := file.GetStringSlice("servers"); err != nil || !reflect.DeepEqual(got, []string{"a", "b", "c"}) {
+		t.Errorf("GetStringSlice: got=%q, err=%v", got, err)
+	}
+
+	var google struct {
+		ClientID string `hcl:"client_id"`
+		Scopes   []string
+	}
+	if err := file.DecodePath("oauth2.google", &google); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := google.ClientID, "id"; got != want {
+		t.Errorf("DecodePath: got=%q, want=%q", got, want)
+	}
+	if got, want := len(google.Scopes), 2; got != want {
+		t.Errorf("DecodePath: got=%d, want=%d", got, want)
+	}
+
+	if !file.Has("database.port") || !file.Has("servers[2]") {
+		t.Error("Has: got=false, want=true")
+	}
+	for _, path := range []string{"database.password", "servers[3]", "service.api", "database.port.x"} {
+		if file.Has(path) {
+			t.Errorf("Has(%q): got=true, want=false", path)
+		}
+	}
+
+	_, err := file.GetString("database.password")
+	if err == nil || !strings.Contains(err.Error(), "database.password") {
+		t.Errorf("got=%v, want error naming path", err)
+	}
+	_, err = file.GetInt("database.hostname")
+	if errs, ok := err.(KeyErrors); !ok || errs[0].Key != "database.hostname" || errs[0].Line != 3 {
+		t.Errorf("got=%v, want KeyErrors for database.hostname", err)
+	}
+}
+
+func TestParsePath(t *testing.T) {
+	tests := []struct {
+		path string
+		want []pathElem
+		err  bool
+	}{
+		{path: "a.b", want: []pathElem{{key: "a"}, {key: "b"}}},
+		{path: `a."b.c"[2]`, want: []pathElem{{key: "a"}, {key: "b.c"}, {index: 2, isIndex: true}}},
+		{path: "a[0].b", want: []pathElem{{key: "a"}, {index: 0, isIndex: true}, {key: "b"}}},
+		{path: "", err: true},
+		{path: "a.", err: true},
+		{path: "a..b", err: true},
+		{path: "a[x]", err: true},
+		{path: `a."b`, err: true},
+		{path: `a"b"`, err: true},
+	}
+	for _, tt := range tests {
+		got, err := parsePath(tt.path)
+		if tt.err {
+			if err == nil {
+				t.Errorf("%s: got nil, want error", tt.path)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got=%+v, want=%+v", tt.path, got, tt.want)
+		}
+	}
+}
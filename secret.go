@@ -0,0 +1,51 @@
+package hclconfig
+
+import (
+	"fmt"
+	"reflect"
+)
+
+// redacted is displayed in place of a secret value.
+const redacted = "[redacted]"
+
+// Secret is a string value that cannot be accidentally logged or
+// displayed. Formatting a secret with the fmt package, or marshaling it
+// as JSON or text, produces a redacted placeholder. Call Reveal to obtain
+// the secret value.
+//
+// When File.Decode populates a field of type Secret, it returns an error
+// if the value was not encrypted in the configuration file. This ensures
+// that secrets are encrypted at rest.
+type Secret string
+
+// Reveal returns the secret value.
+func (s Secret) Reveal() string {
+	return string(s)
+}
+
+// String returns a redacted placeholder.
+func (s Secret) String() string {
+	return redacted
+}
+
+// GoString returns a redacted placeholder.
+func (s Secret) GoString() string {
+	return redacted
+}
+
+// Format writes a redacted placeholder for all verbs.
+func (s Secret) Format(f fmt.State, verb rune) {
+	f.Write([]byte(redacted))
+}
+
+// MarshalJSON returns a redacted placeholder as a JSON string.
+func (s Secret) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + redacted + `"`), nil
+}
+
+// MarshalText returns a redacted placeholder.
+func (s Secret) MarshalText() ([]byte, error) {
+	return []byte(redacted), nil
+}
+
+var secretType = reflect.TypeOf(Secret(""))
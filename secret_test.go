@@ -0,0 +1,109 @@
+package hclconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSecretRedacted(t *testing.T) {
+	s := Secret("s3cret")
+	v := struct {
+		Password Secret
+	}{
+		Password: s,
+	}
+
+	for _, got := range []string{
+		s.String(),
+		s.GoString(),
+		fmt.Sprint(s),
+		fmt.Sprintf("%s %q %v %x", s, s, s, s),
+		fmt.Sprintf("%+v %#v", v, v),
+	} {
+		if strings.Contains(got, "s3cret") {
+			t.Errorf("secret not redacted: %s", got)
+		}
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), `{"Password":"[redacted]"}`; got != want {
+		t.Errorf("got=%s, want=%s", got, want)
+	}
+
+	b, err = s.MarshalText()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(b), redacted; got != want {
+		t.Errorf("got=%s, want=%s", got, want)
+	}
+
+	if got, want := s.Reveal(), "s3cret"; got != want {
+		t.Errorf("got=%s, want=%s", got, want)
+	}
+}
+
+func TestDecodeSecret(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.hcl")
+	writeConfig(t, filename, encryptConfig(t, `
+		encryption {
+			test = true
+		}
+		database "main" {
+			password = "s3cret"
+			hostname = "db.example.com"
+		}
+	`), time.Now())
+
+	loader := &Loader{
+		KeyProviders: []KeyProvider{testKeyProvider},
+	}
+	file, err := loader.Get(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var config struct {
+		Database map[string]struct {
+			Password Secret
+		}
+	}
+	if err := file.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Database["main"].Password.Reveal(), "s3cret"; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+
+	var cleartext struct {
+		Database struct {
+			Main struct {
+				Hostname Secret
+			}
+		}
+	}
+	err = file.Decode(&cleartext)
+	if err == nil {
+		t.Fatal("got nil, want error")
+	}
+	for _, want := range []string{"secret is not encrypted", "database.main.hostname", "line="} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got=%v, want=%s", err, want)
+		}
+	}
+}
@@ -0,0 +1,275 @@
+package hclconfig
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/hashicorp/hcl/hcl/ast"
+	"github.com/hashicorp/hcl/hcl/token"
+	"github.com/jjeffery/errors"
+)
+
+// Validator is implemented by types that check their own values.
+// File.Decode calls the Validate method of every decoded value that
+// implements Validator, and reports any error returned against the
+// key of the value.
+type Validator interface {
+	Validate() error
+}
+
+var validatorType = reflect.TypeOf((*Validator)(nil)).Elem()
+
+// defaultTag returns the default value specified in the hclconfig tag
+// of a field, eg `hclconfig:"default=5432"`. The default value is the
+// remainder of the tag, so it can contain commas.
+func defaultTag(field reflect.StructField) (string, bool) {
+	const prefix = "default="
+	tag := field.Tag.Get("hclconfig")
+	if i := strings.Index(tag, prefix); i >= 0 {
+		return tag[i+len(prefix):], true
+	}
+	return "", false
+}
+
+// decodeMissing is called for a struct field that has no key in the
+// configuration file. It applies any default value, and checks that
+// the field is not required. Struct fields are decoded from an empty
+// object, so that defaults and validation apply to their fields.
+// It returns true if a default value was applied.
+func (d *decoder) decodeMissing(path string, field reflect.StructField, value reflect.Value, pos token.Pos) bool {
+	if s, ok := defaultTag(field); ok && value.IsZero() {
+		d.decode(path, defaultLiteral(s, value.Type(), pos), value)
+		return true
+	}
+
+	if value.IsZero() && hasTag(strings.Split(field.Tag.Get("validate"), ","), "required") {
+		d.errs = append(d.errs, &KeyError{
+			Location: pos.Filename,
+			Key:      path,
+			Line:     pos.Line,
+			Column:   pos.Column,
+			Message:  "required key is missing",
+		})
+		return false
+	}
+
+	if value.Kind() == reflect.Struct && !isLiteralType(value.Type()) {
+		d.pos = pos
+		d.decode(path, &ast.ObjectList{}, value)
+	}
+	return false
+}
+
+// isLiteralType reports whether values of type t are decoded from
+// a single literal value, rather than from an object.
+func isLiteralType(t reflect.Type) bool {
+	return lookupDecodeHook(t) != nil ||
+		t == durationType ||
+		reflect.PtrTo(t).Implements(textUnmarshalerType)
+}
+
+// defaultLiteral returns a literal for decoding the default value s
+// into a value of type t. The token type is chosen so that the default
+// decodes in the same way as a value written in the configuration file.
+func defaultLiteral(s string, t reflect.Type, pos token.Pos) *ast.LiteralType {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice {
+		t = t.Elem()
+	}
+	tok := token.Token{Pos: pos, Text: s}
+	switch {
+	case isLiteralType(t):
+		tok.Type = token.STRING
+	case t.Kind() == reflect.Bool:
+		tok.Type = token.BOOL
+	case t.Kind() >= reflect.Int && t.Kind() <= reflect.Uint64:
+		tok.Type = token.NUMBER
+	case t.Kind() == reflect.Float32 || t.Kind() == reflect.Float64:
+		tok.Type = token.FLOAT
+	default:
+		tok.Type = token.STRING
+	}
+	if tok.Type == token.STRING {
+		tok.Text = strconv.Quote(s)
+		tok.JSON = true
+	}
+	return &ast.LiteralType{Token: tok}
+}
+
+// validateField checks the value of a field against the rules in its
+// validate tag, eg `validate:"min=1,max=65535"`. The following rules
+// are supported:
+//  required          the key must be present, or have a default
+//  min=n, max=n      limits for numbers and durations, or the length
+//                    of strings, slices and maps
+//  oneof=a b c       the value must be one of the space-separated values
+func (d *decoder) validateField(path string, field reflect.StructField, value reflect.Value, pos token.Pos) {
+	tag := field.Tag.Get("validate")
+	if tag == "" {
+		return
+	}
+	for value.Kind() == reflect.Ptr {
+		if value.IsNil() {
+			return
+		}
+		value = value.Elem()
+	}
+
+	for _, rule := range strings.Split(tag, ",") {
+		name, arg := rule, ""
+		if i := strings.Index(rule, "="); i >= 0 {
+			name, arg = rule[:i], rule[i+1:]
+		}
+		var msg string
+		var err error
+		switch name {
+		case "required", "":
+			continue
+		case "min", "max":
+			msg, err = checkLimit(name, arg, value)
+		case "oneof":
+			msg, err = checkOneOf(strings.Fields(arg), value)
+		default:
+			err = errors.New("unknown rule")
+		}
+		if err != nil {
+			msg = fmt.Sprintf("invalid validate tag %q: %v", rule, err)
+		}
+		if msg != "" {
+			d.errs = append(d.errs, &KeyError{
+				Location: pos.Filename,
+				Key:      path,
+				Line:     pos.Line,
+				Column:   pos.Column,
+				Message:  msg,
+			})
+		}
+	}
+}
+
+// checkLimit checks the value against a min or max rule. It returns
+// a message describing the problem, or an empty string if the value
+// is within the limit.
+func checkLimit(name, arg string, value reflect.Value) (string, error) {
+	var n, limit float64
+	var err error
+	var what string
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(value.Int())
+		if value.Type() == durationType {
+			var d time.Duration
+			d, err = time.ParseDuration(arg)
+			limit = float64(d)
+		} else {
+			limit, err = strconv.ParseFloat(arg, 64)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(value.Uint())
+		limit, err = strconv.ParseFloat(arg, 64)
+	case reflect.Float32, reflect.Float64:
+		n = value.Float()
+		limit, err = strconv.ParseFloat(arg, 64)
+	case reflect.String:
+		what = "length "
+		n = float64(utf8.RuneCountInString(value.String()))
+		limit, err = strconv.ParseFloat(arg, 64)
+	case reflect.Slice, reflect.Map, reflect.Array:
+		what = "length "
+		n = float64(value.Len())
+		limit, err = strconv.ParseFloat(arg, 64)
+	default:
+		return "", errors.New("not supported for type").With("type", value.Type())
+	}
+	if err != nil {
+		return "", err
+	}
+	if name == "min" && n < limit {
+		return fmt.Sprintf("%smust be at least %s", what, arg), nil
+	}
+	if name == "max" && n > limit {
+		return fmt.Sprintf("%smust be at most %s", what, arg), nil
+	}
+	return "", nil
+}
+
+// checkOneOf checks that the value is one of the allowed values. The
+// elements of slices are checked individually. The message does not
+// include the value, which could be a secret.
+func checkOneOf(allowed []string, value reflect.Value) (string, error) {
+	if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+		for i := 0; i < value.Len(); i++ {
+			if msg, err := checkOneOf(allowed, reflect.Indirect(value.Index(i))); msg != "" || err != nil {
+				return msg, err
+			}
+		}
+		return "", nil
+	}
+
+	var s string
+	if tm, ok := value.Interface().(encoding.TextMarshaler); ok && value.Type() != secretType {
+		text, err := tm.MarshalText()
+		if err != nil {
+			return "", err
+		}
+		s = string(text)
+	} else {
+		switch value.Kind() {
+		case reflect.String:
+			s = value.String()
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+			s = strconv.FormatInt(value.Int(), 10)
+		case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			s = strconv.FormatUint(value.Uint(), 10)
+		case reflect.Float32, reflect.Float64:
+			s = strconv.FormatFloat(value.Float(), 'g', -1, 64)
+		case reflect.Bool:
+			s = strconv.FormatBool(value.Bool())
+		default:
+			return "", errors.New("not supported for type").With("type", value.Type())
+		}
+	}
+	for _, a := range allowed {
+		if s == a {
+			return "", nil
+		}
+	}
+	return "must be one of: " + strings.Join(allowed, ", "), nil
+}
+
+// callValidate calls the Validate method of the value, if it has one.
+func (d *decoder) callValidate(path string, node ast.Node, value reflect.Value) {
+	if value.CanAddr() {
+		value = value.Addr()
+	}
+	if !value.Type().Implements(validatorType) {
+		return
+	}
+	if err := value.Interface().(Validator).Validate(); err != nil {
+		// report problems with objects at the start of the block
+		pos := d.pos
+		if _, ok := node.(*ast.ObjectList); !ok || !pos.IsValid() {
+			pos = nodePos(node)
+		}
+		d.errs = append(d.errs, &KeyError{
+			Location: pos.Filename,
+			Key:      path,
+			Line:     pos.Line,
+			Column:   pos.Column,
+			Message:  err.Error(),
+		})
+	}
+}
+
+// nodePos returns the position of the node. Unlike node.Pos, it does not
+// panic for an empty object list.
+func nodePos(node ast.Node) token.Pos {
+	if list, ok := node.(*ast.ObjectList); ok && len(list.Items) == 0 {
+		return token.Pos{}
+	}
+	return node.Pos()
+}
@@ -0,0 +1,48 @@
+package hclconfig
+
+import (
+	"context"
+	"time"
+)
+
+// Watch polls the location of the configuration file at the specified
+// interval until the context is cancelled. Each poll calls Refresh, so
+// when the file has changed the new version is downloaded, parsed and
+// decrypted in the same request by the loader that loaded f, and fn is
+// called with the new file. Subsequent polls compare against the new
+// version.
+//
+// If an error occurs while checking for changes or loading the new
+// version, fn is called with a nil file and the error. Errors do not
+// stop the polling: the next poll will try again.
+//
+// Watch blocks until the context is done, and then returns the
+// context's error.
+func (f *File) Watch(ctx context.Context, interval time.Duration, fn func(file *File, err error)) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	current := f
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		file, changed, err := current.RefreshContext(ctx)
+		if ctx.Err() != nil {
+			// do not report a result after cancellation
+			return ctx.Err()
+		}
+		if err != nil {
+			fn(nil, err)
+			continue
+		}
+		if !changed {
+			continue
+		}
+		current = file
+		fn(file, nil)
+	}
+}
@@ -0,0 +1,107 @@
+package hclconfig
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, filename string, contents string, modTime time.Time) {
+	t.Helper()
+	// write to a temporary file and rename, so that a concurrent
+	// watcher never sees a partially written file
+	tmpname := filename + ".tmp"
+	if err := ioutil.WriteFile(tmpname, []byte(contents), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chtimes(tmpname, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(tmpname, filename); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWatch(t *testing.T) {
+	dir, err := ioutil.TempDir("", "hclconfig")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filename := filepath.Join(dir, "config.hcl")
+	modTime := time.Now().Add(-time.Hour)
+	writeConfig(t, filename, `value = "one"`, modTime)
+
+	file, err := Get(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type result struct {
+		file *File
+		err  error
+	}
+	results := make(chan result)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() {
+		done <- file.Watch(ctx, 5*time.Millisecond, func(file *File, err error) {
+			results <- result{file: file, err: err}
+		})
+	}()
+
+	var config struct {
+		Value string
+	}
+
+	// a transient failure is reported, and watching continues
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+	if r := <-results; r.err == nil || r.file != nil {
+		t.Fatalf("got file=%v err=%v, want error", r.file, r.err)
+	}
+
+	writeConfig(t, filename, `value = "two"`, modTime.Add(time.Minute))
+	r := <-results
+	for r.err != nil {
+		// there may be more errors queued before the file was rewritten
+		r = <-results
+	}
+	if err := r.file.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Value, "two"; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+
+	// subsequent changes are detected relative to the new version
+	writeConfig(t, filename, `value = "three"`, modTime.Add(2*time.Minute))
+	if r := <-results; r.err != nil {
+		t.Fatal(r.err)
+	} else if err := r.file.Decode(&config); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := config.Value, "three"; got != want {
+		t.Errorf("got=%q, want=%q", got, want)
+	}
+
+	cancel()
+	for {
+		select {
+		case <-results:
+			// drain any result sent before cancellation
+			continue
+		case err := <-done:
+			if err != context.Canceled {
+				t.Errorf("got=%v, want=%v", err, context.Canceled)
+			}
+		}
+		break
+	}
+}